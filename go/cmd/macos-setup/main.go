@@ -1,16 +1,52 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/schmoli/macos-setup/internal/config"
 	"github.com/schmoli/macos-setup/internal/installer"
+	"github.com/schmoli/macos-setup/internal/paths"
 )
 
-var verbose bool
+var (
+	verbose  bool
+	force    bool
+	dryRun   bool
+	jsonOut  bool
+	failFast bool
+
+	// stdout is where results go; with --json everything else, including
+	// command output, is sent to stderr
+	stdout = os.Stdout
+)
+
+// Exit codes
+const (
+	exitError   = 1 // usage, config or command error
+	exitPartial = 2 // some apps failed
+	exitFailure = 3 // every app failed
+)
+
+// failedError reports that apps failed after the result was printed
+type failedError struct {
+	status string
+}
+
+func (e *failedError) Error() string {
+	return "apps failed: " + e.status
+}
+
+func (e *failedError) code() int {
+	if e.status == installer.StatusFailure {
+		return exitFailure
+	}
+	return exitPartial
+}
 
 func printBanner() {
 	// Gradient styles: cyan -> blue
@@ -32,10 +68,30 @@ func printBanner() {
 func main() {
 	// Parse flags and command
 	var args []string
-	for _, arg := range os.Args[1:] {
-		if arg == "-v" || arg == "--verbose" {
+	rawArgs := os.Args[1:]
+	for i := 0; i < len(rawArgs); i++ {
+		arg := rawArgs[i]
+		switch {
+		case arg == "-v" || arg == "--verbose":
 			verbose = true
-		} else {
+		case arg == "--force":
+			force = true
+		case arg == "--dry-run" || arg == "-n":
+			dryRun = true
+		case arg == "--json":
+			jsonOut = true
+		case arg == "--fail-fast":
+			failFast = true
+		case arg == "--root":
+			if i+1 >= len(rawArgs) {
+				fmt.Fprintln(os.Stderr, "Error: --root needs a directory")
+				os.Exit(1)
+			}
+			i++
+			setRoot(rawArgs[i])
+		case strings.HasPrefix(arg, "--root="):
+			setRoot(strings.TrimPrefix(arg, "--root="))
+		default:
 			args = append(args, arg)
 		}
 	}
@@ -45,17 +101,43 @@ func main() {
 		cmd = args[0]
 	}
 
-	// Show banner
-	printBanner()
+	// Shell completion output must stay clean, so skip the banner
+	if cmd == "__complete" {
+		runComplete(args[1:])
+		return
+	}
+
+	// Show banner, unless stdout is for machines
+	if jsonOut {
+		os.Stdout = os.Stderr
+	} else {
+		printBanner()
+	}
 
 	// Handle help without loading config
 	if cmd == "help" || cmd == "--help" || cmd == "-h" {
-		printHelp()
+		cfg, _ := loadConfig() // best effort, for the category list
+		printHelp(cfg)
 		return
 	}
 
-	// Auto-pull on any command (except help)
-	if installer.AutoPull() {
+	// Validate works on any packages dir, so it runs before auto-pull
+	if cmd == "validate" {
+		dir := paths.Packages()
+		if len(args) > 1 {
+			dir = args[1]
+		}
+		if err := runValidate(dir); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Auto-pull on any command (except help, validate and dry runs)
+	if !dryRun && installer.AutoPull() {
+		// Pulled packages may add or drop init files
+		installer.RefreshShellIntegration()
 		fmt.Println()
 	}
 
@@ -71,50 +153,88 @@ func main() {
 		installer.Status(cfg)
 	case "all":
 		runErr = runInstall(cfg, "")
-	case "cli":
-		runErr = runInstall(cfg, "cli")
-	case "apps":
-		runErr = runInstall(cfg, "apps")
-	case "dev":
-		runErr = runInstall(cfg, "dev")
-	case "docker":
-		runErr = runInstall(cfg, "docker")
-	case "git":
-		runErr = runInstall(cfg, "git")
-	case "browsers":
-		runErr = runInstall(cfg, "browsers")
-	case "mas":
-		runErr = runInstall(cfg, "mas")
+	case "install":
+		runErr = runInstallApps(cfg, args[1:])
+	case "remove":
+		runErr = runRemove(cfg, args[1:])
+	case "hooks":
+		runErr = runHooks(cfg, args[1:])
+	case "configure":
+		runErr = runConfigure(cfg, args[1:])
 	case "update":
-		runErr = installer.Upgrade(cfg)
+		if dryRun {
+			runErr = showPlan(installer.PlanUpgrade(cfg))
+		} else {
+			runErr = runUpdate(cfg)
+		}
 	case "status":
 		installer.Status(cfg)
 	default:
-		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
-		printHelp()
-		os.Exit(1)
+		if !cfg.HasCategory(cmd) {
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", cmd)
+			printHelp(cfg)
+			os.Exit(1)
+		}
+		runErr = runInstall(cfg, cmd)
 	}
 
-	if runErr != nil {
+	var failed *failedError
+	if runErr != nil && !errors.As(runErr, &failed) {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", runErr)
-		os.Exit(1)
+		os.Exit(exitError)
 	}
 
-	// Check if zshrc was modified
-	if installer.CheckZshrcModified() {
+	// Check if rc files were modified
+	if rcFiles := installer.ModifiedRcFiles(); len(rcFiles) > 0 {
 		fmt.Println()
-		installer.LogWarn("Run: source ~/.zshrc")
+		for _, rc := range rcFiles {
+			installer.LogWarn("Run: source " + paths.Tilde(rc))
+		}
+	}
+
+	if failed != nil {
+		os.Exit(failed.code())
 	}
 }
 
+// setRoot sandboxes boots under dir, which is created if needed
+func setRoot(dir string) {
+	abs, err := filepath.Abs(dir)
+	if err == nil {
+		err = os.MkdirAll(abs, 0755)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: --root: %v\n", err)
+		os.Exit(1)
+	}
+	paths.SetRoot(abs)
+}
+
 func loadConfig() (*config.Config, error) {
-	home, _ := os.UserHomeDir()
-	packagesDir := filepath.Join(home, ".config", "boots", "repo", "packages")
-	return config.Load(packagesDir)
+	return config.Load(paths.Packages())
+}
+
+func runValidate(dir string) error {
+	problems, err := config.Validate(dir)
+	if err != nil {
+		return err
+	}
+
+	if len(problems) == 0 {
+		installer.LogSuccess(fmt.Sprintf("All packages in %s are valid", dir))
+		return nil
+	}
+
+	files := make(map[string]bool)
+	for _, p := range problems {
+		installer.LogFail(p.String())
+		files[p.Path] = true
+	}
+	return fmt.Errorf("%d problems in %d files", len(problems), len(files))
 }
 
 func runInstall(cfg *config.Config, category string) error {
-	apps := cfg.Apps
+	apps := cfg.VisibleApps()
 	if category != "" {
 		apps = cfg.FilterByCategory(category)
 	}
@@ -124,46 +244,275 @@ func runInstall(cfg *config.Config, category string) error {
 		return nil
 	}
 
-	result, err := installer.Install(apps, verbose)
+	label := "tools"
+	if category != "" {
+		label = category + " tools"
+	}
+	return installApps(cfg, apps, label)
+}
+
+// runInstallApps installs the apps named on the command line
+func runInstallApps(cfg *config.Config, refs []string) error {
+	if len(refs) == 0 {
+		return fmt.Errorf("usage: boots install <app...>")
+	}
+
+	apps, err := lookupApps(cfg, refs)
 	if err != nil {
 		return err
 	}
+	return installApps(cfg, apps, "requested apps")
+}
 
-	// Print summary
-	fmt.Println()
-	if len(result.Installed) > 0 {
-		installer.LogSuccess(fmt.Sprintf("Installed: %v", result.Installed))
+// lookupApps resolves app names or IDs, reporting every one that fails
+func lookupApps(cfg *config.Config, refs []string) (map[string]config.App, error) {
+	apps := make(map[string]config.App)
+	var errs []string
+	for _, ref := range refs {
+		id, app, err := cfg.Lookup(ref)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		apps[id] = app
 	}
-	if len(result.Failed) > 0 {
-		installer.LogFail(fmt.Sprintf("Failed: %v", result.Failed))
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
 	}
-	if len(result.Installed) == 0 && len(result.Failed) == 0 {
-		label := "tools"
-		if category != "" {
-			label = category + " tools"
+	return apps, nil
+}
+
+// installApps installs apps plus their dependencies and prints a summary
+func installApps(cfg *config.Config, apps map[string]config.App, label string) error {
+	// Pull in dependencies from other categories
+	apps, err := cfg.WithDependencies(apps)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return showPlan(installer.PlanInstall(apps))
+	}
+
+	result, err := installer.Install(apps, failFast, verbose)
+	if err != nil {
+		return err
+	}
+
+	return finish(result, func() {
+		if len(result.Installed) > 0 {
+			installer.LogSuccess(fmt.Sprintf("Installed: %v", result.Installed))
+		}
+		if len(result.Installed) == 0 && len(result.Failed) == 0 {
+			installer.LogSuccess(fmt.Sprintf("All %s installed", label))
 		}
-		installer.LogSuccess(fmt.Sprintf("All %s installed", label))
+	})
+}
+
+// runRemove uninstalls the apps named on the command line
+func runRemove(cfg *config.Config, refs []string) error {
+	if len(refs) == 0 {
+		return fmt.Errorf("usage: boots remove <app...>")
+	}
+
+	apps, err := lookupApps(cfg, refs)
+	if err != nil {
+		return err
 	}
 
+	if dryRun {
+		return showPlan(installer.PlanRemove(cfg, apps, force))
+	}
+
+	result, err := installer.Remove(cfg, apps, force, verbose)
+	if err != nil {
+		return err
+	}
+
+	return finish(result, func() {
+		if len(result.Removed) > 0 {
+			installer.LogSuccess(fmt.Sprintf("Removed: %v", result.Removed))
+		}
+		if len(result.Skipped) > 0 {
+			installer.LogDim(fmt.Sprintf("Not installed: %v", result.Skipped))
+		}
+	})
+}
+
+// runHooks reruns post_install hooks for the apps named on the command line
+func runHooks(cfg *config.Config, refs []string) error {
+	if len(refs) == 0 {
+		return fmt.Errorf("usage: boots hooks <app...>")
+	}
+
+	apps, err := lookupApps(cfg, refs)
+	if err != nil {
+		return err
+	}
+
+	if dryRun {
+		return showPlan(installer.PlanHooks(apps), nil)
+	}
+
+	result := installer.RunHooks(apps, failFast, verbose)
+	return finish(result, func() {
+		if len(result.Configured) > 0 {
+			installer.LogSuccess(fmt.Sprintf("Hooks ran: %v", result.Configured))
+		}
+		if len(result.Skipped) > 0 {
+			installer.LogDim(fmt.Sprintf("No hooks: %v", result.Skipped))
+		}
+	})
+}
+
+// runConfigure configures the apps named on the command line, or every
+// installed app
+func runConfigure(cfg *config.Config, refs []string) error {
+	apps := cfg.Apps
+	if len(refs) > 0 {
+		var err error
+		if apps, err = lookupApps(cfg, refs); err != nil {
+			return err
+		}
+	}
+
+	if dryRun {
+		return showPlan(installer.PlanConfigure(cfg, apps))
+	}
+
+	result, err := installer.Configure(cfg, apps, failFast, verbose)
+	if err != nil {
+		return err
+	}
+
+	return finish(result, func() {
+		if len(refs) > 0 && len(result.Skipped) > 0 {
+			installer.LogDim(fmt.Sprintf("Not installed: %v", result.Skipped))
+		}
+		if len(result.Failed) == 0 {
+			installer.LogSuccess(fmt.Sprintf("Configured %d apps", len(result.Configured)))
+		}
+	})
+}
+
+// runUpdate upgrades the tracked apps and configures every installed app
+func runUpdate(cfg *config.Config) error {
+	result, err := installer.Update(cfg, failFast, verbose)
+	if err != nil {
+		return err
+	}
+
+	return finish(result, func() {
+		if len(result.Failed) == 0 {
+			installer.LogSuccess(fmt.Sprintf("Upgraded %d apps, configured %d", len(result.Upgraded), len(result.Configured)))
+		}
+	})
+}
+
+// finish prints a result, as JSON with --json or else via summary followed
+// by the failures, and returns a *failedError if any app failed
+func finish(result *installer.Result, summary func()) error {
+	status := result.Status()
+
+	if jsonOut {
+		doc := struct {
+			Status string `json:"status"`
+			*installer.Result
+		}{status, result}
+		if err := installer.WriteJSON(stdout, doc); err != nil {
+			return err
+		}
+	} else {
+		fmt.Println()
+		summary()
+		for _, f := range result.Failures {
+			installer.LogFail(fmt.Sprintf("Failed: %s (%s): %s", f.App, f.Phase, f.Error))
+		}
+	}
+
+	if status != installer.StatusSuccess {
+		return &failedError{status: status}
+	}
+	return nil
+}
+
+// showPlan prints a dry-run plan, as JSON with --json
+func showPlan(plan *installer.Plan, err error) error {
+	if err != nil {
+		return err
+	}
+	if jsonOut {
+		return installer.WriteJSON(stdout, plan)
+	}
+	installer.PrintPlan(plan)
 	return nil
 }
 
-func printHelp() {
+// runComplete prints completion candidates, one per line, for the
+// generated zsh completion function
+func runComplete(args []string) {
+	cfg, err := loadConfig()
+	if err != nil {
+		cfg = &config.Config{}
+	}
+
+	if len(args) == 0 || args[0] == "commands" {
+		// Categories are added from the loaded config
+		candidates := append([]string{}, config.Commands...)
+		for _, cat := range cfg.VisibleCategories() {
+			candidates = append(candidates, cat.Name)
+		}
+		fmt.Println(strings.Join(candidates, "\n"))
+		return
+	}
+
+	if args[0] == "apps" {
+		fmt.Println(strings.Join(cfg.Refs(), "\n"))
+	}
+}
+
+// printHelp prints usage, listing the categories in cfg if it loaded
+func printHelp(cfg *config.Config) {
 	fmt.Println("boots - macOS bootstrapper")
 	fmt.Println()
 	fmt.Println("Usage:")
 	fmt.Println("  boots              Show status")
 	fmt.Println("  boots all          Install all apps")
-	fmt.Println("  boots cli          Install CLI tools")
-	fmt.Println("  boots apps         Install desktop apps")
-	fmt.Println("  boots dev          Install development tools")
-	fmt.Println("  boots docker       Install docker tools")
-	fmt.Println("  boots git          Install git tools")
-	fmt.Println("  boots browsers     Install browsers")
-	fmt.Println("  boots mas          Install App Store apps")
-	fmt.Println("  boots update       Upgrade tracked apps")
+	defaults := false
+	if cfg != nil {
+		for _, cat := range cfg.VisibleCategories() {
+			desc := cat.Description
+			if desc == "" {
+				desc = "Install " + cat.Title
+			}
+			marker := " "
+			if cat.Default {
+				marker = "*"
+				defaults = true
+			}
+			fmt.Printf("  boots %-12s%s%s\n", cat.Name, marker, desc)
+		}
+	}
+	fmt.Println("  boots install      Install specific apps <app...>")
+	fmt.Println("  boots remove       Uninstall specific apps <app...>")
+	fmt.Println("  boots configure    Configure installed apps [app...]")
+	fmt.Println("  boots hooks        Rerun post_install hooks <app...>")
+	fmt.Println("  boots update       Upgrade tracked apps, then configure")
+	fmt.Println("  boots validate     Check package files for errors [dir]")
 	fmt.Println("  boots help         Show this help")
+	if defaults {
+		fmt.Println()
+		fmt.Println("  * selected by default")
+	}
 	fmt.Println()
 	fmt.Println("Flags:")
 	fmt.Println("  -v, --verbose    Show command details on failure")
+	fmt.Println("  -n, --dry-run    Show what install/update/remove would do")
+	fmt.Println("  --json           Print the result, or the dry-run plan, as JSON")
+	fmt.Println("  --force          Remove apps even if others depend on them")
+	fmt.Println("  --fail-fast      Stop an app's post_install hooks at the first failure")
+	fmt.Println("  --root <dir>     Sandbox every file boots touches under dir")
+	fmt.Println()
+	fmt.Println("Environment:")
+	fmt.Println("  BOOTS_HOME       boots directory (default ~/.config/boots)")
 }
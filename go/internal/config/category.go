@@ -0,0 +1,87 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Category describes a packages/<category> directory. Metadata comes from
+// an optional category.yaml; without one a category is titled by its name.
+type Category struct {
+	Name        string `yaml:"-"`           // directory name
+	Title       string `yaml:"title"`       // display name in status
+	Description string `yaml:"description"` // shown in help
+	Order       int    `yaml:"order"`       // sort order, unset sorts last
+	Default     bool   `yaml:"default"`     // preselected in pickers
+	Hidden      bool   `yaml:"hidden"`      // left out of help, status and "all"
+}
+
+// loadCategory reads catPath/category.yaml, ignoring a missing or broken
+// file like Load does for app.yaml
+func loadCategory(catPath, name string) Category {
+	cat := Category{Name: name}
+	if data, err := os.ReadFile(filepath.Join(catPath, "category.yaml")); err == nil {
+		yaml.Unmarshal(data, &cat)
+	}
+	cat.Name = name
+	if cat.Title == "" {
+		cat.Title = name
+	}
+	return cat
+}
+
+// sortCategories orders categories by Order, then name. Categories without
+// an order come after those with one.
+func sortCategories(cats []Category) {
+	sort.SliceStable(cats, func(i, j int) bool {
+		a, b := cats[i], cats[j]
+		if (a.Order == 0) != (b.Order == 0) {
+			return a.Order != 0
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.Name < b.Name
+	})
+}
+
+// Category returns the category with the given name
+func (c *Config) Category(name string) (Category, bool) {
+	for _, cat := range c.Categories {
+		if cat.Name == name {
+			return cat, true
+		}
+	}
+	return Category{}, false
+}
+
+// HasCategory reports whether name is a discovered category
+func (c *Config) HasCategory(name string) bool {
+	_, ok := c.Category(name)
+	return ok
+}
+
+// VisibleCategories returns the categories that aren't hidden, in order
+func (c *Config) VisibleCategories() []Category {
+	var cats []Category
+	for _, cat := range c.Categories {
+		if !cat.Hidden {
+			cats = append(cats, cat)
+		}
+	}
+	return cats
+}
+
+// VisibleApps returns apps outside hidden categories
+func (c *Config) VisibleApps() map[string]App {
+	result := make(map[string]App)
+	for id, app := range c.Apps {
+		if cat, ok := c.Category(app.Category); !ok || !cat.Hidden {
+			result[id] = app
+		}
+	}
+	return result
+}
@@ -1,37 +1,87 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"gopkg.in/yaml.v3"
 )
 
+// Config holds all apps keyed by their "category/name" ID
 type Config struct {
-	Apps map[string]App
+	Apps       map[string]App
+	Categories []Category // categories that contain apps, in display order
 }
 
 type App struct {
-	Install     string     `yaml:"install"`
-	Category    string     `yaml:"-"` // inferred from path
-	Description string     `yaml:"description"`
-	Package     string     `yaml:"package"`
-	ID          int        `yaml:"id"`
-	Config      *AppConfig `yaml:"config"`
-	PostInstall []string   `yaml:"post_install"`
-	Depends     []string   `yaml:"depends"`
-	Init        bool       `yaml:"init"` // marks app as init/base tool
+	Install     string            `yaml:"install"`
+	Name        string            `yaml:"-"` // inferred from folder name
+	Category    string            `yaml:"-"` // inferred from path
+	Description string            `yaml:"description"`
+	Package     string            `yaml:"package"`
+	ID          int               `yaml:"id"`
+	Config      *AppConfig        `yaml:"config"`
+	PostInstall []Hook            `yaml:"post_install"`
+	Completions string            `yaml:"completions"` // command printing the zsh completion script
+	GitConfig   map[string]string `yaml:"git_config"`  // global git settings, key -> value
+	Env         map[string]string `yaml:"env"`         // environment variables for the shell
+	Path        []string          `yaml:"path"`        // directories added to PATH
+	Aliases     map[string]string `yaml:"aliases"`     // shell aliases, name -> command
+	Depends     []string          `yaml:"depends"`
+	Init        bool              `yaml:"init"` // marks app as init/base tool
 }
 
+// AppConfig is a config file shipped with a package and deployed by boots
 type AppConfig struct {
-	Source string `yaml:"source"`
-	Dest   string `yaml:"dest"`
+	Source   string `yaml:"source"`   // relative to the app's directory
+	Dest     string `yaml:"dest"`     // target path, ~ expands to home
+	Mode     string `yaml:"mode"`     // symlink (default) or copy
+	Template bool   `yaml:"template"` // render source as a Go template, implies copy
+}
+
+// Config file deploy modes
+const (
+	ModeSymlink = "symlink"
+	ModeCopy    = "copy"
+)
+
+// DeployMode returns the deploy mode, defaulting to symlink. Templates are
+// always copied, since the rendered file differs from the source.
+func (c AppConfig) DeployMode() string {
+	if c.Template {
+		return ModeCopy
+	}
+	if c.Mode == "" {
+		return ModeSymlink
+	}
+	return c.Mode
+}
+
+// Hook is a post_install command. In app.yaml it's either a plain command
+// string or a mapping with run and optional guards.
+type Hook struct {
+	Run     string `yaml:"run"`
+	Creates string `yaml:"creates"` // skip if this path exists
+	Unless  string `yaml:"unless"`  // skip if this command succeeds
+}
+
+// UnmarshalYAML accepts both the string and the mapping form
+func (h *Hook) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*h = Hook{Run: value.Value}
+		return nil
+	}
+	type plain Hook
+	return value.Decode((*plain)(h))
 }
 
 // Load scans packages/<category>/<name>/app.yaml files
 func Load(appsDir string) (*Config, error) {
 	cfg := &Config{Apps: make(map[string]App)}
+	meta := make(map[string]Category)
 
 	// Scan category directories
 	categories, err := os.ReadDir(appsDir)
@@ -45,6 +95,7 @@ func Load(appsDir string) (*Config, error) {
 		}
 		catName := cat.Name()
 		catPath := filepath.Join(appsDir, catName)
+		meta[catName] = loadCategory(catPath, catName)
 
 		// Scan app directories within category
 		apps, err := os.ReadDir(catPath)
@@ -69,14 +120,122 @@ func Load(appsDir string) (*Config, error) {
 				continue
 			}
 
+			app.Name = appName
 			app.Category = catName
-			cfg.Apps[appName] = app
+			cfg.Apps[AppID(catName, appName)] = app
 		}
 	}
 
+	cfg.resolveDepends()
+	cfg.Categories = cfg.categoriesWithApps(meta)
+
 	return cfg, nil
 }
 
+// categoriesWithApps returns the categories that contain apps, in display
+// order, using metadata from meta where present
+func (c *Config) categoriesWithApps(meta map[string]Category) []Category {
+	seen := make(map[string]bool)
+	var cats []Category
+	for _, app := range c.Apps {
+		if seen[app.Category] {
+			continue
+		}
+		seen[app.Category] = true
+		cat, ok := meta[app.Category]
+		if !ok {
+			cat = Category{Name: app.Category, Title: app.Category}
+		}
+		cats = append(cats, cat)
+	}
+	sortCategories(cats)
+	return cats
+}
+
+// AppID returns the canonical "category/name" ID of an app
+func AppID(category, name string) string {
+	return category + "/" + name
+}
+
+// PackageName returns the package to install, defaulting to the app name
+func (a App) PackageName() string {
+	if a.Package != "" {
+		return a.Package
+	}
+	return a.Name
+}
+
+// Dir returns the app's package directory within appsDir
+func (a App) Dir(appsDir string) string {
+	return filepath.Join(appsDir, a.Category, a.Name)
+}
+
+// Lookup resolves an app reference to its ID. References are either a full
+// "category/name" ID or a bare name, which must match exactly one app.
+func (c *Config) Lookup(ref string) (string, App, error) {
+	if app, ok := c.Apps[ref]; ok {
+		return ref, app, nil
+	}
+	if strings.Contains(ref, "/") {
+		return "", App{}, fmt.Errorf("unknown app %q", ref)
+	}
+
+	var candidates []string
+	for id, app := range c.Apps {
+		if app.Name == ref {
+			candidates = append(candidates, id)
+		}
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", App{}, fmt.Errorf("unknown app %q", ref)
+	case 1:
+		return candidates[0], c.Apps[candidates[0]], nil
+	default:
+		sort.Strings(candidates)
+		return "", App{}, fmt.Errorf("ambiguous app %q, use one of: %s", ref, strings.Join(candidates, ", "))
+	}
+}
+
+// Refs returns every way to refer to an app on the command line: all IDs,
+// plus bare names that are unambiguous
+func (c *Config) Refs() []string {
+	count := make(map[string]int)
+	for _, app := range c.Apps {
+		count[app.Name]++
+	}
+
+	var refs []string
+	for id, app := range c.Apps {
+		refs = append(refs, id)
+		if count[app.Name] == 1 {
+			refs = append(refs, app.Name)
+		}
+	}
+	sort.Strings(refs)
+	return refs
+}
+
+// resolveDepends rewrites dependency references to app IDs. References that
+// don't resolve are kept as-is so WithDependencies can report them.
+func (c *Config) resolveDepends() {
+	for id, app := range c.Apps {
+		if len(app.Depends) == 0 {
+			continue
+		}
+		deps := make([]string, len(app.Depends))
+		for i, ref := range app.Depends {
+			deps[i] = ref
+			if depID, _, err := c.Lookup(ref); err == nil {
+				deps[i] = depID
+			}
+		}
+		app.Depends = deps
+		c.Apps[id] = app
+	}
+}
+
 // LoadLegacy loads from single apps.yaml (for migration)
 func LoadLegacy(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -91,7 +250,14 @@ func LoadLegacy(path string) (*Config, error) {
 		return nil, err
 	}
 
-	return &Config{Apps: wrapper.Apps}, nil
+	for name, app := range wrapper.Apps {
+		app.Name = name
+		wrapper.Apps[name] = app
+	}
+
+	cfg := &Config{Apps: wrapper.Apps}
+	cfg.Categories = cfg.categoriesWithApps(nil)
+	return cfg, nil
 }
 
 // HasInitZsh checks if app has init.zsh in repo
@@ -101,11 +267,11 @@ func HasInitZsh(appsDir, category, name string) bool {
 	return err == nil
 }
 
-// AppsByCategory returns apps grouped by category
+// AppsByCategory returns app IDs grouped by category
 func (c *Config) AppsByCategory() map[string][]string {
 	result := make(map[string][]string)
-	for name, app := range c.Apps {
-		result[app.Category] = append(result[app.Category], name)
+	for id, app := range c.Apps {
+		result[app.Category] = append(result[app.Category], id)
 	}
 	return result
 }
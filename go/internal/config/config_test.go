@@ -0,0 +1,224 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePackages creates packages/<category>/<name>/app.yaml files from a
+// map of "category/name" to file contents
+func writePackages(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for id, content := range files {
+		appDir := filepath.Join(dir, id)
+		if err := os.MkdirAll(appDir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(appDir, "app.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadKeysAppsByID(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"apps/localsend": "install: cask\ndescription: AirDrop alternative\n",
+		"dev/localsend":  "install: cask\ndescription: AirDrop alternative\n",
+	})
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	if len(cfg.Apps) != 2 {
+		t.Fatalf("loaded %d apps, want both localsend entries", len(cfg.Apps))
+	}
+	if app := cfg.Apps["dev/localsend"]; app.Name != "localsend" || app.Category != "dev" {
+		t.Errorf("dev/localsend = %+v", app)
+	}
+}
+
+func TestLoadDiscoversCategories(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"cli/jq":       "install: brew\ndescription: JSON processor\n",
+		"browsers/zen": "install: cask\ndescription: Zen browser\n",
+	})
+	// A category directory without apps isn't a category
+	if err := os.MkdirAll(filepath.Join(dir, "docker"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	var names []string
+	for _, cat := range cfg.Categories {
+		names = append(names, cat.Name)
+	}
+	if want := []string{"browsers", "cli"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("Categories = %v, want %v", names, want)
+	}
+}
+
+func TestLoadHookForms(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"dev/k9s": `install: brew
+description: K8s TUI
+post_install:
+  - k9s info
+  - run: k9s completion zsh > ~/_k9s
+    creates: ~/_k9s
+  - run: git config --global core.pager delta
+    unless: git config --global core.pager
+`,
+	})
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	want := []Hook{
+		{Run: "k9s info"},
+		{Run: "k9s completion zsh > ~/_k9s", Creates: "~/_k9s"},
+		{Run: "git config --global core.pager delta", Unless: "git config --global core.pager"},
+	}
+	if got := cfg.Apps["dev/k9s"].PostInstall; !reflect.DeepEqual(got, want) {
+		t.Errorf("PostInstall = %+v, want %+v", got, want)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	cfg := &Config{Apps: map[string]App{
+		"cli/jq":         {Name: "jq", Category: "cli"},
+		"apps/localsend": {Name: "localsend", Category: "apps"},
+		"dev/localsend":  {Name: "localsend", Category: "dev"},
+	}}
+
+	if id, _, err := cfg.Lookup("jq"); err != nil || id != "cli/jq" {
+		t.Errorf("Lookup(jq) = %q, %v", id, err)
+	}
+	if id, _, err := cfg.Lookup("dev/localsend"); err != nil || id != "dev/localsend" {
+		t.Errorf("Lookup(dev/localsend) = %q, %v", id, err)
+	}
+
+	_, _, err := cfg.Lookup("localsend")
+	if err == nil || !strings.Contains(err.Error(), "apps/localsend, dev/localsend") {
+		t.Errorf("Lookup(localsend) error = %v, want candidates listed", err)
+	}
+	if _, _, err := cfg.Lookup("nope"); err == nil {
+		t.Error("Lookup(nope) succeeded")
+	}
+}
+
+func TestWithDependencies(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"cli/node":    "install: brew\ndescription: Node\n",
+		"cli/pnpm":    "install: npm\ndescription: pnpm\ndepends: [node]\n",
+		"dev/aws-cdk": "install: npm\ndescription: CDK\ndepends: [pnpm]\n",
+	})
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	apps, err := cfg.WithDependencies(cfg.FilterByCategory("dev"))
+	if err != nil {
+		t.Fatalf("WithDependencies: %v", err)
+	}
+	levels, err := InstallOrder(apps)
+	if err != nil {
+		t.Fatalf("InstallOrder: %v", err)
+	}
+
+	want := [][]string{{"cli/node"}, {"cli/pnpm"}, {"dev/aws-cdk"}}
+	if !reflect.DeepEqual(levels, want) {
+		t.Errorf("InstallOrder = %v, want %v", levels, want)
+	}
+}
+
+func TestWithDependenciesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "missing",
+			files: map[string]string{"cli/a": "install: brew\ndepends: [ghost]\n"},
+			want:  `cli/a: unknown app "ghost"`,
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"cli/a": "install: brew\ndepends: [b]\n",
+				"cli/b": "install: brew\ndepends: [a]\n",
+			},
+			want: "dependency cycle: cli/a -> cli/b -> cli/a",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Load(writePackages(t, tt.files))
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+			_, err = cfg.WithDependencies(cfg.Apps)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadCategoryMetadata(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"cli/jq":       "install: brew\ndescription: JSON processor\n",
+		"apps/claude":  "install: cask\ndescription: Claude desktop\n",
+		"beta/thing":   "install: brew\ndescription: Experimental\n",
+		"browsers/zen": "install: cask\ndescription: Zen browser\n",
+	})
+	files := map[string]string{
+		"cli":  "title: CLI tools\norder: 2\ndefault: true\n",
+		"apps": "title: Desktop apps\norder: 1\n",
+		"beta": "hidden: true\n",
+	}
+	for cat, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, cat, "category.yaml"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+
+	var names []string
+	for _, cat := range cfg.VisibleCategories() {
+		names = append(names, cat.Name)
+	}
+	if want := []string{"apps", "cli", "browsers"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("VisibleCategories = %v, want %v", names, want)
+	}
+
+	cli, _ := cfg.Category("cli")
+	if cli.Title != "CLI tools" || !cli.Default {
+		t.Errorf("cli = %+v", cli)
+	}
+	if zen, _ := cfg.Category("browsers"); zen.Title != "browsers" {
+		t.Errorf("browsers title = %q, want the directory name", zen.Title)
+	}
+	if !cfg.HasCategory("beta") {
+		t.Error("hidden category should still be installable")
+	}
+	if _, ok := cfg.VisibleApps()["beta/thing"]; ok {
+		t.Error("VisibleApps includes an app from a hidden category")
+	}
+}
@@ -0,0 +1,148 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// WithDependencies returns apps plus everything they transitively depend on
+func (c *Config) WithDependencies(apps map[string]App) (map[string]App, error) {
+	result := make(map[string]App)
+	var visit func(name string, app App) error
+	visit = func(name string, app App) error {
+		if _, ok := result[name]; ok {
+			return nil
+		}
+		result[name] = app
+		for _, dep := range app.Depends {
+			depApp, ok := c.Apps[dep]
+			if !ok {
+				_, _, err := c.Lookup(dep)
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			if err := visit(dep, depApp); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	for _, name := range sortedNames(apps) {
+		if err := visit(name, apps[name]); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := checkCycles(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// InstallOrder groups apps into levels where each level only depends on
+// earlier ones. Dependencies outside of apps are assumed to be satisfied.
+func InstallOrder(apps map[string]App) ([][]string, error) {
+	if err := checkCycles(apps); err != nil {
+		return nil, err
+	}
+
+	done := make(map[string]bool)
+	var levels [][]string
+	for len(done) < len(apps) {
+		var level []string
+		for _, name := range sortedNames(apps) {
+			if done[name] {
+				continue
+			}
+			ready := true
+			for _, dep := range apps[name].Depends {
+				if _, ok := apps[dep]; ok && !done[dep] {
+					ready = false
+					break
+				}
+			}
+			if ready {
+				level = append(level, name)
+			}
+		}
+		for _, name := range level {
+			done[name] = true
+		}
+		levels = append(levels, level)
+	}
+
+	return levels, nil
+}
+
+// CycleError is a dependency cycle, starting and ending at the same app
+type CycleError struct {
+	Cycle []string
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle: %s", strings.Join(e.Cycle, " -> "))
+}
+
+// checkCycles returns a *CycleError for the first dependency cycle found
+func checkCycles(apps map[string]App) error {
+	if cycles := findCycles(apps); len(cycles) > 0 {
+		return cycles[0]
+	}
+	return nil
+}
+
+// findCycles returns every dependency cycle, one per dependency that
+// closes a loop, in the order a walk of the sorted apps meets them
+func findCycles(apps map[string]App) []*CycleError {
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	marks := make(map[string]int)
+	var path []string
+	var cycles []*CycleError
+
+	var visit func(name string)
+	visit = func(name string) {
+		switch marks[name] {
+		case visiting:
+			start := 0
+			for i, p := range path {
+				if p == name {
+					start = i
+					break
+				}
+			}
+			cycle := append(append([]string{}, path[start:]...), name)
+			cycles = append(cycles, &CycleError{Cycle: cycle})
+			return
+		case visited:
+			return
+		}
+
+		marks[name] = visiting
+		path = append(path, name)
+		for _, dep := range apps[name].Depends {
+			if _, ok := apps[dep]; ok {
+				visit(dep)
+			}
+		}
+		path = path[:len(path)-1]
+		marks[name] = visited
+	}
+
+	for _, name := range sortedNames(apps) {
+		visit(name)
+	}
+	return cycles
+}
+
+func sortedNames[V any](m map[string]V) []string {
+	names := make([]string, 0, len(m))
+	for name := range m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
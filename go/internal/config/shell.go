@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// Shells lists the shells boots can set up, each with an init.<shell> file
+// per package
+var Shells = []string{"zsh", "bash", "fish"}
+
+// ShellEntry is an env var or alias declared by an app
+type ShellEntry struct {
+	App   string
+	Name  string
+	Value string
+}
+
+// ShellConflict is an env var or alias that two apps set differently
+type ShellConflict struct {
+	Kind  string // env or alias
+	Name  string
+	App   string // the app whose value is dropped
+	Other string // the app whose value is kept
+}
+
+func (c ShellConflict) String() string {
+	return fmt.Sprintf("%s %s conflicts with %s", c.Kind, c.Name, c.Other)
+}
+
+// ShellInit is the merged env, path and aliases of a set of apps
+type ShellInit struct {
+	Env       []ShellEntry // sorted by name
+	Path      []string     // in app order, first occurrence kept
+	Aliases   []ShellEntry // sorted by name
+	Conflicts []ShellConflict
+}
+
+// Empty reports whether there's nothing to render
+func (si ShellInit) Empty() bool {
+	return len(si.Env) == 0 && len(si.Path) == 0 && len(si.Aliases) == 0
+}
+
+var (
+	envNameRe   = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	aliasNameRe = regexp.MustCompile(`^[A-Za-z0-9_.+-]+$`)
+)
+
+// MergeShell merges the env, path and aliases of apps. Apps are taken in
+// ID order, so when two set the same name differently the first keeps it
+// and the clash is reported in Conflicts.
+func MergeShell(apps map[string]App) ShellInit {
+	var si ShellInit
+	env := make(map[string]ShellEntry)
+	aliases := make(map[string]ShellEntry)
+	seen := make(map[string]bool)
+
+	merge := func(kind string, into map[string]ShellEntry, id string, values map[string]string) {
+		for _, name := range sortedNames(values) {
+			entry := ShellEntry{App: id, Name: name, Value: values[name]}
+			if prev, ok := into[name]; ok {
+				if prev.Value != entry.Value {
+					si.Conflicts = append(si.Conflicts, ShellConflict{Kind: kind, Name: name, App: id, Other: prev.App})
+				}
+				continue
+			}
+			into[name] = entry
+		}
+	}
+
+	for _, id := range sortedNames(apps) {
+		app := apps[id]
+		merge("env", env, id, app.Env)
+		merge("alias", aliases, id, app.Aliases)
+		for _, dir := range app.Path {
+			if !seen[dir] {
+				seen[dir] = true
+				si.Path = append(si.Path, dir)
+			}
+		}
+	}
+
+	si.Env = sortedEntries(env)
+	si.Aliases = sortedEntries(aliases)
+	return si
+}
+
+func sortedEntries(entries map[string]ShellEntry) []ShellEntry {
+	sorted := make([]ShellEntry, 0, len(entries))
+	for _, e := range entries {
+		sorted = append(sorted, e)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
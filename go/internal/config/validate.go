@@ -0,0 +1,386 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// InstallTypes lists the supported values for the install field
+var InstallTypes = []string{"brew", "cask", "npm", "mas", "shell"}
+
+// Commands lists boots' built-in top-level commands. A category with one
+// of these names could never be installed with `boots <category>`.
+var Commands = []string{
+	"all", "install", "remove", "configure", "hooks", "update", "status", "validate", "help",
+}
+
+// Problem is a single validation finding in a package file
+type Problem struct {
+	Path    string
+	Line    int // 0 when the problem isn't tied to a line
+	Message string
+}
+
+func (p Problem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("%s:%d: %s", p.Path, p.Line, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// parsedApp is an app.yaml that decoded well enough to cross-check
+type parsedApp struct {
+	path      string
+	app       App
+	dependsAt []int          // line of each depends entry
+	shellAt   map[string]int // line of each env and alias entry, by "kind name"
+}
+
+var yamlLineRe = regexp.MustCompile(`^(?:yaml: )?line (\d+): (.*)$`)
+
+// Validate checks every app.yaml under appsDir and returns all problems
+// found. The error is only set when appsDir itself can't be read.
+func Validate(appsDir string) ([]Problem, error) {
+	categories, err := os.ReadDir(appsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var problems []Problem
+	cfg := &Config{Apps: make(map[string]App)}
+	parsed := make(map[string]parsedApp)
+
+	for _, cat := range categories {
+		if !cat.IsDir() {
+			continue
+		}
+		catName := cat.Name()
+		catPath := filepath.Join(appsDir, catName)
+		if contains(Commands, catName) {
+			problems = append(problems, Problem{Path: catPath, Message: fmt.Sprintf("category %q clashes with the boots %s command", catName, catName)})
+		}
+
+		catYaml := filepath.Join(catPath, "category.yaml")
+		if data, err := os.ReadFile(catYaml); err == nil {
+			problems = append(problems, validateCategory(catYaml, data)...)
+		}
+
+		apps, err := os.ReadDir(catPath)
+		if err != nil {
+			problems = append(problems, Problem{Path: catPath, Message: err.Error()})
+			continue
+		}
+
+		for _, appDir := range apps {
+			if !appDir.IsDir() {
+				continue
+			}
+			appName := appDir.Name()
+			appYaml := filepath.Join(catPath, appName, "app.yaml")
+
+			data, err := os.ReadFile(appYaml)
+			if err != nil {
+				if os.IsNotExist(err) {
+					err = errors.New("missing app.yaml")
+				}
+				problems = append(problems, Problem{Path: appYaml, Message: err.Error()})
+				continue
+			}
+
+			p, found := validateApp(appYaml, data)
+			problems = append(problems, found...)
+			if p == nil {
+				continue
+			}
+
+			p.app.Name = appName
+			p.app.Category = catName
+			id := AppID(catName, appName)
+			cfg.Apps[id] = p.app
+			parsed[id] = *p
+		}
+	}
+
+	// Cross-check dependencies now that every app is known
+	for _, id := range sortedNames(cfg.Apps) {
+		p := parsed[id]
+		for i, ref := range p.app.Depends {
+			if _, _, err := cfg.Lookup(ref); err != nil {
+				problems = append(problems, Problem{Path: p.path, Line: p.dependsAt[i], Message: "depends: " + err.Error()})
+			}
+		}
+	}
+	// Env vars and aliases must agree across packages
+	for _, c := range MergeShell(cfg.Apps).Conflicts {
+		p := parsed[c.App]
+		problems = append(problems, Problem{Path: p.path, Line: p.shellAt[c.Kind+" "+c.Name], Message: c.String()})
+	}
+
+	cfg.resolveDepends()
+	for _, cycle := range findCycles(cfg.Apps) {
+		// Report each cycle at the first app's depends entry that starts it
+		id := cycle.Cycle[0]
+		p := parsed[id]
+		problem := Problem{Path: p.path, Message: cycle.Error()}
+		if i := slices.Index(cfg.Apps[id].Depends, cycle.Cycle[1]); i >= 0 && i < len(p.dependsAt) {
+			problem.Line = p.dependsAt[i]
+		}
+		problems = append(problems, problem)
+	}
+
+	sortProblems(problems)
+	return problems, nil
+}
+
+// validateApp checks a single app.yaml. It returns nil for the app if the
+// file is too broken to take part in dependency checks.
+func validateApp(path string, data []byte) (*parsedApp, []Problem) {
+	var problems []Problem
+	add := func(line int, format string, args ...any) {
+		problems = append(problems, Problem{Path: path, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	var root yaml.Node
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		line, msg := splitYAMLError(err.Error())
+		add(line, "%s", msg)
+		return nil, problems
+	}
+	if len(root.Content) == 0 {
+		add(0, "empty file")
+		return nil, problems
+	}
+	doc := root.Content[0]
+	if doc.Kind != yaml.MappingNode {
+		add(doc.Line, "expected a mapping of fields")
+		return nil, problems
+	}
+
+	known := knownFields()
+	for i := 0; i < len(doc.Content); i += 2 {
+		key := doc.Content[i]
+		if !known[key.Value] {
+			add(key.Line, "unknown field %q", key.Value)
+		}
+	}
+
+	var app App
+	if err := doc.Decode(&app); err != nil {
+		var typeErr *yaml.TypeError
+		if errors.As(err, &typeErr) {
+			for _, e := range typeErr.Errors {
+				add(splitYAMLError(e))
+			}
+		} else {
+			line, msg := splitYAMLError(err.Error())
+			add(line, "%s", msg)
+		}
+	}
+
+	installNode := mappingValue(doc, "install")
+	switch {
+	case installNode == nil:
+		add(0, "missing install")
+	case !contains(InstallTypes, installNode.Value):
+		add(installNode.Line, "unsupported install type %q (want %s)", installNode.Value, strings.Join(InstallTypes, ", "))
+	case app.Install == "mas" && app.ID == 0:
+		add(installNode.Line, "mas app requires id")
+	case app.Install == "shell" && len(app.Env) == 0 && len(app.Path) == 0 && len(app.Aliases) == 0:
+		found := false
+		for _, shell := range Shells {
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), "init."+shell)); err == nil {
+				found = true
+			}
+		}
+		if !found {
+			add(installNode.Line, "shell app requires an init file (init.zsh, init.bash or init.fish), env, path or aliases")
+		}
+	}
+
+	if strings.TrimSpace(app.Description) == "" {
+		add(0, "missing description")
+	}
+
+	if node := mappingValue(doc, "config"); node != nil && app.Config != nil {
+		c := app.Config
+		switch {
+		case c.Source == "" || c.Dest == "":
+			add(node.Line, "config: needs source and dest")
+		case c.Mode != "" && c.Mode != ModeSymlink && c.Mode != ModeCopy:
+			add(mappingValue(node, "mode").Line, "config: unsupported mode %q (want %s, %s)", c.Mode, ModeSymlink, ModeCopy)
+		case c.Template && c.Mode == ModeSymlink:
+			add(mappingValue(node, "mode").Line, "config: templates are rendered and copied, they can't be symlinked")
+		default:
+			data, err := os.ReadFile(filepath.Join(filepath.Dir(path), c.Source))
+			if err != nil {
+				add(mappingValue(node, "source").Line, "config: source %s not found", c.Source)
+			} else if c.Template {
+				if _, err := template.New(c.Source).Parse(string(data)); err != nil {
+					add(mappingValue(node, "source").Line, "config: %v", err)
+				}
+			}
+		}
+	}
+
+	if hooks := mappingValue(doc, "post_install"); hooks != nil && hooks.Kind == yaml.SequenceNode {
+		for _, hook := range hooks.Content {
+			problems = append(problems, validateHook(path, hook)...)
+		}
+	}
+
+	p := &parsedApp{path: path, app: app, shellAt: make(map[string]int)}
+	checkNames := func(field, kind string, nameRe *regexp.Regexp) {
+		node := mappingValue(doc, field)
+		if node == nil || node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if !nameRe.MatchString(key.Value) {
+				add(key.Line, "%s: invalid name %q", field, key.Value)
+			}
+			p.shellAt[kind+" "+key.Value] = key.Line
+		}
+	}
+	checkNames("env", "env", envNameRe)
+	checkNames("aliases", "alias", aliasNameRe)
+	if node := mappingValue(doc, "path"); node != nil && node.Kind == yaml.SequenceNode {
+		for _, dir := range node.Content {
+			if strings.TrimSpace(dir.Value) == "" {
+				add(dir.Line, "path: empty entry")
+			}
+		}
+	}
+
+	if deps := mappingValue(doc, "depends"); deps != nil && deps.Kind == yaml.SequenceNode {
+		for _, dep := range deps.Content {
+			p.dependsAt = append(p.dependsAt, dep.Line)
+		}
+	}
+	if len(p.dependsAt) != len(app.Depends) {
+		p.app.Depends = nil
+	}
+
+	return p, problems
+}
+
+// hookFields are the keys of a post_install entry in mapping form
+var hookFields = []string{"run", "creates", "unless"}
+
+// validateHook checks one post_install entry, which is a command string or
+// a mapping with run and optional creates/unless
+func validateHook(path string, node *yaml.Node) []Problem {
+	var problems []Problem
+	add := func(line int, format string, args ...any) {
+		problems = append(problems, Problem{Path: path, Line: line, Message: fmt.Sprintf(format, args...)})
+	}
+
+	switch node.Kind {
+	case yaml.ScalarNode:
+		if strings.TrimSpace(node.Value) == "" {
+			add(node.Line, "post_install: empty command")
+		}
+	case yaml.MappingNode:
+		for i := 0; i < len(node.Content); i += 2 {
+			key := node.Content[i]
+			if !contains(hookFields, key.Value) {
+				add(key.Line, "post_install: unknown field %q (want %s)", key.Value, strings.Join(hookFields, ", "))
+			}
+		}
+		if run := mappingValue(node, "run"); run == nil || strings.TrimSpace(run.Value) == "" {
+			add(node.Line, "post_install: missing run")
+		}
+	default:
+		add(node.Line, "post_install: expected a command or a mapping with run")
+	}
+	return problems
+}
+
+// validateCategory checks a category.yaml for syntax errors and unknown
+// fields
+func validateCategory(path string, data []byte) []Problem {
+	var problems []Problem
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+
+	var cat Category
+	err := dec.Decode(&cat)
+	var typeErr *yaml.TypeError
+	switch {
+	case err == nil || errors.Is(err, io.EOF):
+	case errors.As(err, &typeErr):
+		for _, e := range typeErr.Errors {
+			line, msg := splitYAMLError(e)
+			problems = append(problems, Problem{Path: path, Line: line, Message: msg})
+		}
+	default:
+		line, msg := splitYAMLError(err.Error())
+		problems = append(problems, Problem{Path: path, Line: line, Message: msg})
+	}
+	return problems
+}
+
+// knownFields returns the yaml keys accepted in app.yaml
+func knownFields() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(App{})
+	for i := 0; i < t.NumField(); i++ {
+		tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]
+		if tag != "" && tag != "-" {
+			fields[tag] = true
+		}
+	}
+	return fields
+}
+
+// mappingValue returns the value node for key in a mapping node
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// splitYAMLError extracts the line number from a yaml error message
+func splitYAMLError(msg string) (int, string) {
+	msg = strings.TrimSpace(msg)
+	if m := yamlLineRe.FindStringSubmatch(msg); m != nil {
+		line, _ := strconv.Atoi(m[1])
+		return line, m[2]
+	}
+	return 0, strings.TrimPrefix(msg, "yaml: ")
+}
+
+func contains(slice []string, item string) bool {
+	for _, s := range slice {
+		if s == item {
+			return true
+		}
+	}
+	return false
+}
+
+// sortProblems orders problems by path and line
+func sortProblems(problems []Problem) {
+	sort.SliceStable(problems, func(i, j int) bool {
+		if problems[i].Path != problems[j].Path {
+			return problems[i].Path < problems[j].Path
+		}
+		return problems[i].Line < problems[j].Line
+	})
+}
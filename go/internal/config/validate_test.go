@@ -0,0 +1,94 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"cli/jq":     "install: brew\ndescription: JSON processor\n",
+		"cli/typo":   "install: brw\ndescripton: oops\n",
+		"cli/broken": "install: brew\n  description: : x\n",
+		"cli/tmux":   "install: brew\ndescription: Terminal multiplexer\nconfig:\n  source: tmux.conf\n  dest: ~/.tmux.conf\n",
+		"apps/xcode": "install: mas\ndescription: IDE\n",
+		"dev/cdk":    "install: npm\ndescription: CDK\ndepends:\n  - node\n",
+		"dev/k9s":    "install: brew\ndescription: K8s TUI\npost_install:\n  - k9s version\n  - creates: ~/_k9s\n    rn: k9s completion zsh\n",
+		"cli/bat":    "install: brew\ndescription: Cat clone\naliases:\n  cat: bat --paging=never\nenv:\n  MANPAGER: bat -plman\n",
+		"dev/a":      "install: brew\ndescription: A\ndepends:\n  - jq\n  - dev/b\n",
+		"dev/b":      "install: brew\ndescription: B\ndepends:\n  - a\n",
+		"cli/ccat":   "install: brew\ndescription: Colorizing cat\naliases:\n  cat: ccat\nenv:\n  MANPAGER: bat -plman\n  BAD-NAME: x\n",
+		"update/mu":  "install: brew\ndescription: Mail updater\n",
+		"dev/x":      "install: brew\ndescription: X\ndepends:\n  - dev/y\n",
+		"dev/y":      "install: brew\ndescription: Y\ndepends:\n  - dev/x\n",
+	})
+
+	problems, err := Validate(dir)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+
+	want := []string{
+		dir + "/apps/xcode/app.yaml:1: mas app requires id",
+		dir + "/cli/broken/app.yaml:2: mapping values are not allowed in this context",
+		dir + "/cli/ccat/app.yaml:4: alias cat conflicts with cli/bat",
+		dir + "/cli/ccat/app.yaml:7: env: invalid name \"BAD-NAME\"",
+		dir + "/cli/tmux/app.yaml:4: config: source tmux.conf not found",
+		dir + "/cli/typo/app.yaml: missing description",
+		dir + "/cli/typo/app.yaml:1: unsupported install type \"brw\" (want brew, cask, npm, mas, shell)",
+		dir + "/cli/typo/app.yaml:2: unknown field \"descripton\"",
+		dir + "/dev/a/app.yaml:5: dependency cycle: dev/a -> dev/b -> dev/a",
+		dir + "/dev/cdk/app.yaml:4: depends: unknown app \"node\"",
+		dir + "/dev/k9s/app.yaml:5: post_install: missing run",
+		dir + "/dev/k9s/app.yaml:6: post_install: unknown field \"rn\" (want run, creates, unless)",
+		dir + "/dev/x/app.yaml:4: dependency cycle: dev/x -> dev/y -> dev/x",
+		dir + "/update: category \"update\" clashes with the boots update command",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("got %d problems, want %d: %v", len(problems), len(want), problems)
+	}
+	for i, p := range problems {
+		if p.String() != want[i] {
+			t.Errorf("problem %d = %q, want %q", i, p.String(), want[i])
+		}
+	}
+}
+
+func TestValidateCategoryYaml(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"cli/jq": "install: brew\ndescription: JSON processor\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "cli", "category.yaml"), []byte("title: CLI\nordr: 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Validate(dir)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	want := dir + "/cli/category.yaml:2: field ordr not found in type config.Category"
+	if len(problems) != 1 || problems[0].String() != want {
+		t.Errorf("problems = %v, want [%s]", problems, want)
+	}
+}
+
+func TestValidateShellApp(t *testing.T) {
+	dir := writePackages(t, map[string]string{
+		"shell/empty":   "install: shell\ndescription: Nothing to set up\n",
+		"shell/prompt":  "install: shell\ndescription: Fish prompt\n",
+		"shell/aliases": "install: shell\ndescription: Aliases\naliases:\n  ll: ls -l\n",
+	})
+	if err := os.WriteFile(filepath.Join(dir, "shell", "prompt", "init.fish"), []byte("function fish_prompt; end\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	problems, err := Validate(dir)
+	if err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	want := dir + "/shell/empty/app.yaml:1: shell app requires an init file (init.zsh, init.bash or init.fish), env, path or aliases"
+	if len(problems) != 1 || problems[0].String() != want {
+		t.Errorf("problems = %v, want [%s]", problems, want)
+	}
+}
@@ -0,0 +1,132 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/runner"
+)
+
+// Backend installs and manages apps of one or more install types. All
+// methods take apps keyed by ID and only receive apps of their own types.
+type Backend interface {
+	// Installed returns the IDs of apps that are already present
+	Installed(apps map[string]config.App) map[string]bool
+	// Install installs apps as a batch and returns an error per failed app
+	Install(apps map[string]config.App, verbose bool) map[string]error
+	// Upgrade upgrades apps to their latest version
+	Upgrade(apps map[string]config.App, verbose bool) error
+	// Uninstall removes apps and returns an error per failed app
+	Uninstall(apps map[string]config.App, verbose bool) map[string]error
+	// Commands returns the commands an operation would run, for dry runs
+	Commands(op Op, apps map[string]config.App) []runner.Cmd
+	// Version returns the installed version of app, or "" if unknown
+	Version(app config.App) string
+}
+
+// Op is a mutating backend operation
+type Op string
+
+const (
+	OpInstall   Op = "install"
+	OpUpgrade   Op = "upgrade"
+	OpUninstall Op = "uninstall"
+)
+
+var (
+	backends     = make(map[string]Backend)
+	backendOrder []string // install types in registration order
+)
+
+// Register makes a backend available for an install type. Registering the
+// same backend for several types lets it batch them together.
+func Register(installType string, b Backend) {
+	if _, ok := backends[installType]; !ok {
+		backendOrder = append(backendOrder, installType)
+	}
+	backends[installType] = b
+}
+
+// BackendFor returns the backend for an app's install type
+func BackendFor(app config.App) (Backend, bool) {
+	b, ok := backends[app.Install]
+	return b, ok
+}
+
+func init() {
+	registerDefaults()
+}
+
+// registerDefaults registers fresh instances of the built-in backends
+func registerDefaults() {
+	backends = make(map[string]Backend)
+	backendOrder = nil
+
+	brew := &brewBackend{}
+	Register("brew", brew)
+	Register("cask", brew)
+	Register("npm", &npmBackend{})
+	Register("shell", &shellBackend{})
+	Register("mas", &masBackend{}) // last, as it may prompt
+}
+
+// backendGroup is a set of apps handled by the same backend
+type backendGroup struct {
+	backend Backend
+	apps    map[string]config.App
+}
+
+// groupByBackend splits apps by backend in registration order. Apps with no
+// registered backend are returned separately.
+func groupByBackend(apps map[string]config.App) ([]backendGroup, []string) {
+	var groups []backendGroup
+	index := make(map[Backend]int)
+	for _, installType := range backendOrder {
+		b := backends[installType]
+		if _, ok := index[b]; !ok {
+			index[b] = len(groups)
+			groups = append(groups, backendGroup{backend: b, apps: make(map[string]config.App)})
+		}
+	}
+
+	var unsupported []string
+	for _, id := range sortedKeys(apps) {
+		app := apps[id]
+		b, ok := BackendFor(app)
+		if !ok {
+			unsupported = append(unsupported, id)
+			continue
+		}
+		groups[index[b]].apps[id] = app
+	}
+
+	// Drop empty groups
+	var result []backendGroup
+	for _, g := range groups {
+		if len(g.apps) > 0 {
+			result = append(result, g)
+		}
+	}
+	return result, unsupported
+}
+
+// run executes external commands; tests swap in a runner.Fake
+var run runner.Runner = runner.Exec{}
+
+// SetRunner replaces the runner used for all external commands
+func SetRunner(r runner.Runner) {
+	run = r
+}
+
+// runCmd runs a command with output streamed to the terminal, logging the
+// command line and exit code on failure in verbose mode
+func runCmd(verbose bool, cmd runner.Cmd) error {
+	err := run.Run(cmd)
+	if err != nil && verbose {
+		LogFail(fmt.Sprintf("Command: %s", cmd))
+		if code := runner.ExitCode(err); code >= 0 {
+			LogFail(fmt.Sprintf("Exit code: %d", code))
+		}
+	}
+	return err
+}
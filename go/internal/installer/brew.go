@@ -0,0 +1,198 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/runner"
+)
+
+const brewBin = "/opt/homebrew/bin/brew"
+
+// brewBackend handles brew formulae and casks, batching both into one
+// brew bundle run. Formulae and casks are listed separately so a cask is
+// never satisfied by a formula of the same name, or the other way round.
+type brewBackend struct {
+	versions map[string]map[string]string // install type -> package -> version, nil when stale
+}
+
+// listing returns installed packages of the given install type ("brew" or
+// "cask") with their versions
+func (b *brewBackend) listing(kind string) map[string]string {
+	if b.versions == nil {
+		b.versions = make(map[string]map[string]string)
+	}
+	if _, ok := b.versions[kind]; !ok {
+		b.versions[kind] = brewVersions(kind)
+	}
+	return b.versions[kind]
+}
+
+func (b *brewBackend) Installed(apps map[string]config.App) map[string]bool {
+	installed := make(map[string]bool)
+	for id, app := range apps {
+		if _, ok := b.listing(app.Install)[app.PackageName()]; ok {
+			installed[id] = true
+		}
+	}
+	return installed
+}
+
+func (b *brewBackend) Install(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+
+	brewfile, err := GenerateBrewfile(apps)
+	if err != nil {
+		for id := range apps {
+			failed[id] = err
+		}
+		return failed
+	}
+	if brewfile == "" {
+		return failed
+	}
+	defer os.Remove(brewfile)
+
+	LogProgress(fmt.Sprintf("Installing %d packages...", len(apps)))
+	if err := runCmd(verbose, b.bundleCmd()); err != nil {
+		// Some may have failed, but continue
+		LogWarn("brew bundle had errors")
+	}
+
+	// Check what actually got installed
+	b.versions = nil
+	installed := b.Installed(apps)
+	for id := range apps {
+		if !installed[id] {
+			failed[id] = fmt.Errorf("not installed after brew bundle")
+		}
+	}
+	return failed
+}
+
+func (b *brewBackend) Upgrade(apps map[string]config.App, verbose bool) error {
+	LogProgress(fmt.Sprintf("Upgrading %d brew packages...", len(apps)))
+	defer func() { b.versions = nil }()
+	return runCmd(verbose, b.upgradeCmd(apps))
+}
+
+func (b *brewBackend) Uninstall(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+	defer func() { b.versions = nil }()
+
+	for _, id := range sortedKeys(apps) {
+		LogProgress(fmt.Sprintf("Removing %s...", id))
+		if err := runCmd(verbose, b.uninstallCmd(apps[id])); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
+
+func (b *brewBackend) Commands(op Op, apps map[string]config.App) []runner.Cmd {
+	switch op {
+	case OpInstall:
+		return []runner.Cmd{b.bundleCmd()}
+	case OpUpgrade:
+		return []runner.Cmd{b.upgradeCmd(apps)}
+	case OpUninstall:
+		var cmds []runner.Cmd
+		for _, id := range sortedKeys(apps) {
+			cmds = append(cmds, b.uninstallCmd(apps[id]))
+		}
+		return cmds
+	}
+	return nil
+}
+
+func (b *brewBackend) bundleCmd() runner.Cmd {
+	return runner.Command(brewBin, "bundle", "--file="+paths.Brewfile())
+}
+
+func (b *brewBackend) upgradeCmd(apps map[string]config.App) runner.Cmd {
+	args := []string{"upgrade"}
+	for _, id := range sortedKeys(apps) {
+		args = append(args, apps[id].PackageName())
+	}
+	return runner.Command(brewBin, args...)
+}
+
+func (b *brewBackend) uninstallCmd(app config.App) runner.Cmd {
+	args := []string{"uninstall"}
+	if app.Install == "cask" {
+		args = append(args, "--cask")
+	}
+	return runner.Command(brewBin, append(args, app.PackageName())...)
+}
+
+func (b *brewBackend) Version(app config.App) string {
+	return b.listing(app.Install)[app.PackageName()]
+}
+
+// brewVersions returns installed formulae (kind "brew") or casks (kind
+// "cask") with their versions
+func brewVersions(kind string) map[string]string {
+	flag := "--formula"
+	if kind == "cask" {
+		flag = "--cask"
+	}
+
+	versions := make(map[string]string)
+	out, err := run.Output(runner.Command(brewBin, "list", flag, "--versions"))
+	if err != nil {
+		return versions
+	}
+	for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		// Multiple versions may be installed; the last is the newest
+		versions[fields[0]] = fields[len(fields)-1]
+	}
+	return versions
+}
+
+// GenerateBrewfile creates a temp Brewfile for the given apps
+func GenerateBrewfile(apps map[string]config.App) (string, error) {
+	content := BrewfileContent(apps)
+	if content == "" {
+		return "", nil
+	}
+
+	tmpFile := paths.Brewfile()
+	if err := os.MkdirAll(filepath.Dir(tmpFile), 0755); err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
+		return "", err
+	}
+
+	return tmpFile, nil
+}
+
+// BrewfileContent returns Brewfile lines for the brew and cask apps
+func BrewfileContent(apps map[string]config.App) string {
+	var lines []string
+
+	for _, id := range sortedKeys(apps) {
+		app := apps[id]
+		pkg := app.PackageName()
+
+		switch app.Install {
+		case "brew":
+			lines = append(lines, fmt.Sprintf("brew \"%s\"", pkg))
+		case "cask":
+			lines = append(lines, fmt.Sprintf("cask \"%s\"", pkg))
+		}
+	}
+
+	if len(lines) == 0 {
+		return ""
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
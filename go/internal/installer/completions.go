@@ -0,0 +1,71 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+)
+
+// completionFile returns where app's generated zsh completion goes
+func completionFile(app config.App) string {
+	return filepath.Join(paths.Completions(), "_"+app.Name)
+}
+
+// hasCompletion reports whether app's completion file is in place, or app
+// doesn't generate one
+func hasCompletion(app config.App) bool {
+	if app.Completions == "" {
+		return true
+	}
+	_, err := os.Stat(completionFile(app))
+	return err == nil
+}
+
+// writeCompletion runs app's completions command and saves its output in
+// the completions directory. The file is only replaced if the command
+// succeeds, so a broken generator keeps the last good completion.
+func writeCompletion(app config.App) error {
+	if app.Completions == "" {
+		return nil
+	}
+
+	out, err := run.Output(zshCmd(hookPreamble(app) + app.Completions))
+	if err != nil {
+		return fmt.Errorf("%s: %w", app.Completions, err)
+	}
+	if len(out) == 0 {
+		return fmt.Errorf("%s: no output", app.Completions)
+	}
+
+	path := completionFile(app)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, out, 0644); err != nil {
+		return err
+	}
+
+	// compinit -C trusts its dump, so drop it to pick up the new file
+	os.Remove(filepath.Join(paths.Home(), ".zcompdump"))
+	return nil
+}
+
+// removeCompletion deletes app's generated completion, if any
+func removeCompletion(app config.App) {
+	if app.Completions != "" {
+		os.Remove(completionFile(app))
+	}
+}
+
+// refreshCompletions regenerates completions for apps, after an upgrade
+// may have changed them
+func refreshCompletions(apps map[string]config.App) {
+	for _, id := range sortedKeys(apps) {
+		if err := writeCompletion(apps[id]); err != nil {
+			LogWarn(fmt.Sprintf("%s: completions: %v", id, err))
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package installer
+
+import (
+	"fmt"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// configureApp deploys an installed app's config file, applies its git
+// settings, generates its completion and runs its hooks, returning the
+// phase that failed. It's safe to run repeatedly: deployed files, applied
+// settings, existing completions and, unless force is set, hooks that
+// already succeeded are left alone. Fresh installs force the hooks, since
+// the app may have been removed outside boots since they last ran.
+func configureApp(id string, app config.App, force, failFast, verbose bool) (string, error) {
+	if err := deployDotfile(id, app); err != nil {
+		return PhaseConfig, err
+	}
+	if err := applyGitConfig(id, app, verbose); err != nil {
+		return PhaseGitConfig, err
+	}
+	if !hasCompletion(app) {
+		if err := writeCompletion(app); err != nil {
+			return PhaseCompletions, err
+		}
+	}
+	if err := runHooks(id, app, force, failFast, verbose); err != nil {
+		return PhaseHooks, err
+	}
+	return "", nil
+}
+
+// needsConfigure reports whether configureApp has anything to do or report
+// for app
+func needsConfigure(s *state.State, id string, app config.App) bool {
+	if len(pendingHooks(s, id, app)) > 0 || !hasCompletion(app) {
+		return true
+	}
+	if pending, drifted := pendingGitConfig(s, id, app); len(pending)+len(drifted) > 0 {
+		return true
+	}
+	dotfile := checkDotfile(app, s.Dotfiles[id])
+	return dotfile != dotfileNone && dotfile != dotfileDeployed
+}
+
+// Configure applies configuration to the installed apps among apps, however
+// they were installed. Installed apps that boots doesn't track yet are
+// adopted into state, so their shell integration is loaded and update
+// upgrades them. Apps that aren't installed are skipped; the rest end up in
+// Configured unless their config file or a hook fails.
+func Configure(cfg *config.Config, apps map[string]config.App, failFast, verbose bool) (*Result, error) {
+	result := newResult("configure")
+
+	s, err := loadState(cfg)
+	if err != nil {
+		return result, err
+	}
+
+	installed := make(map[string]bool)
+	groups, _ := groupByBackend(apps)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			installed[id] = true
+		}
+	}
+
+	var present []string
+	for _, id := range sortedKeys(apps) {
+		if !installed[id] {
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+		if !s.IsTracked(id) {
+			LogDim(fmt.Sprintf("Adopting %s", id))
+			s.MarkInstalled(id)
+		}
+		present = append(present, id)
+	}
+
+	// configureApp saves state itself, so s is only read from here on
+	for _, id := range present {
+		app := apps[id]
+		if needsConfigure(s, id, app) {
+			LogProgress(fmt.Sprintf("Configuring %s...", id))
+			if phase, err := configureApp(id, app, false, failFast, verbose); err != nil {
+				result.fail(id, phase, err)
+				continue
+			}
+		}
+		result.Configured = append(result.Configured, id)
+	}
+
+	RefreshShellIntegration()
+	return result, nil
+}
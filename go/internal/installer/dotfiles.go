@@ -0,0 +1,288 @@
+package installer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/settings"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// Dotfile states, from checkDotfile
+const (
+	dotfileNone     = ""         // app ships no config file
+	dotfilePending  = "pending"  // not deployed yet
+	dotfileDeployed = "deployed" // in place and unchanged
+	dotfileOutdated = "outdated" // a copy whose source or template vars changed
+	dotfileDrifted  = "drifted"  // edited or replaced locally
+)
+
+// backupSuffix is appended to files moved aside by a deploy
+const backupSuffix = ".boots-backup"
+
+// dotfileFor returns the deploy record app's config file would get
+func dotfileFor(app config.App) state.Dotfile {
+	return state.Dotfile{
+		Source: filepath.Join(app.Dir(paths.Packages()), app.Config.Source),
+		Dest:   expandPath(app.Config.Dest),
+		Mode:   app.Config.DeployMode(),
+	}
+}
+
+// checkDotfile compares app's config file on disk with what boots
+// deployed, given the deploy record from state (zero if none)
+func checkDotfile(app config.App, rec state.Dotfile) string {
+	if app.Config == nil {
+		return dotfileNone
+	}
+	want := dotfileFor(app)
+	deployed := rec.Dest == want.Dest && rec.Mode == want.Mode
+
+	if _, err := os.Lstat(want.Dest); err != nil {
+		return dotfilePending
+	}
+
+	if want.Mode == config.ModeSymlink {
+		target, err := os.Readlink(want.Dest)
+		if err == nil && target == want.Source {
+			return dotfileDeployed
+		}
+		// Our link to a source that was renamed or moved with the repo
+		if err == nil && deployed && target == rec.Source {
+			return dotfileOutdated
+		}
+		if deployed {
+			return dotfileDrifted
+		}
+		return dotfilePending
+	}
+
+	if !deployed {
+		return dotfilePending
+	}
+	if hashFile(want.Dest) != rec.Hash {
+		return dotfileDrifted
+	}
+	// A changed source, or for templates changed variables, means the
+	// deployed copy is out of date
+	content, err := dotfileContent(app, want.Source)
+	if err != nil || hashBytes(content) != rec.Hash {
+		return dotfileOutdated
+	}
+	return dotfileDeployed
+}
+
+// dotfileContent returns what a copied config file should contain: the
+// source, rendered with templateVars if the app's config is a template
+func dotfileContent(app config.App, src string) ([]byte, error) {
+	data, err := os.ReadFile(src)
+	if err != nil || !app.Config.Template {
+		return data, err
+	}
+
+	vars, err := templateVars()
+	if err != nil {
+		return nil, err
+	}
+	tmpl, err := template.New(app.Config.Source).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// TemplateVars are the values config templates can use, e.g.
+// {{ .BrewPrefix }} or {{ .Vars.email }}
+type TemplateVars struct {
+	Home       string
+	BrewPrefix string
+	Hostname   string // short name, without .local
+	Arch       string // arm64 or amd64
+	Profile    string // from settings.yaml
+	Vars       map[string]string
+}
+
+// templateVars collects the machine values and the user's settings
+func templateVars() (TemplateVars, error) {
+	s, err := settings.Load()
+	if err != nil {
+		return TemplateVars{}, err
+	}
+
+	hostname, _ := os.Hostname()
+	hostname, _, _ = strings.Cut(hostname, ".")
+
+	return TemplateVars{
+		Home:       paths.Home(),
+		BrewPrefix: brewPrefix(),
+		Hostname:   hostname,
+		Arch:       runtime.GOARCH,
+		Profile:    s.Profile,
+		Vars:       s.Vars,
+	}, nil
+}
+
+// brewPrefix returns where Homebrew lives: $HOMEBREW_PREFIX if set, else
+// the default for the architecture
+func brewPrefix() string {
+	if prefix := os.Getenv("HOMEBREW_PREFIX"); prefix != "" {
+		return prefix
+	}
+	if runtime.GOARCH == "arm64" {
+		return "/opt/homebrew"
+	}
+	return "/usr/local"
+}
+
+// deployDotfile puts app's config file in place, moving aside any file it
+// would replace. Files edited locally since the last deploy are left alone.
+func deployDotfile(id string, app config.App) error {
+	s, _ := state.Load()
+	rec := s.Dotfiles[id]
+	replace := false
+
+	switch checkDotfile(app, rec) {
+	case dotfileNone, dotfileDeployed:
+		return nil
+	case dotfileDrifted:
+		LogWarn(fmt.Sprintf("%s: %s was changed locally, leaving it alone", id, paths.Tilde(rec.Dest)))
+		return nil
+	case dotfilePending:
+		prev := rec
+		rec = dotfileFor(app)
+		if prev.Dest == rec.Dest {
+			// Redeploying over our own file, e.g. after a mode change:
+			// replace it, and keep the backup of the user's original
+			rec.Backup = prev.Backup
+			if ownsDotfile(prev) {
+				os.Remove(prev.Dest)
+			}
+		} else if prev.Dest != "" {
+			// The dest moved, so put the old one back as it was
+			restoreDotfile(id, prev)
+		}
+	case dotfileOutdated:
+		// Our own file, replaced below keeping the backup of the original
+		replace = true
+		rec.Source = dotfileFor(app).Source
+	}
+
+	src := dotfileFor(app).Source
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("config source: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(rec.Dest), 0755); err != nil {
+		return err
+	}
+
+	// Move aside a file boots didn't put there, never over an earlier backup
+	if replace {
+		os.Remove(rec.Dest)
+	} else if _, err := os.Lstat(rec.Dest); err == nil && rec.Hash == "" {
+		backup := rec.Dest + backupSuffix
+		if _, err := os.Lstat(backup); err == nil {
+			return fmt.Errorf("%s already exists, not replacing it", paths.Tilde(backup))
+		}
+		rec.Backup = backup
+		if err := os.Rename(rec.Dest, rec.Backup); err != nil {
+			return err
+		}
+		LogDim(fmt.Sprintf("Backed up %s", paths.Tilde(rec.Backup)))
+	}
+
+	if rec.Mode == config.ModeCopy {
+		content, err := dotfileContent(app, src)
+		if err != nil {
+			return err
+		}
+		if err := writeCopy(src, rec.Dest, content); err != nil {
+			return err
+		}
+		rec.Hash = hashBytes(content)
+	} else if err := os.Symlink(src, rec.Dest); err != nil {
+		return err
+	}
+
+	LogDim(fmt.Sprintf("Deployed %s", paths.Tilde(rec.Dest)))
+	s.RecordDotfile(id, rec)
+	return nil
+}
+
+// removeDotfile deletes the config file deployed for id, unless it was
+// changed locally, and puts back the file it replaced. It updates s without
+// saving it.
+func removeDotfile(s *state.State, id string) {
+	rec, ok := s.Dotfiles[id]
+	if !ok {
+		return
+	}
+	delete(s.Dotfiles, id)
+	restoreDotfile(id, rec)
+}
+
+// restoreDotfile deletes the file deployed per rec, unless it was changed
+// locally, and puts back the file it replaced
+func restoreDotfile(id string, rec state.Dotfile) {
+	if !ownsDotfile(rec) {
+		if _, err := os.Lstat(rec.Dest); err == nil {
+			LogWarn(fmt.Sprintf("%s: %s was changed locally, leaving it", id, paths.Tilde(rec.Dest)))
+		}
+		return
+	}
+
+	os.Remove(rec.Dest)
+	if rec.Backup != "" {
+		if err := os.Rename(rec.Backup, rec.Dest); err == nil {
+			LogDim(fmt.Sprintf("Restored %s", paths.Tilde(rec.Dest)))
+		}
+	}
+}
+
+// ownsDotfile reports whether the file at rec.Dest is still the one boots
+// deployed
+func ownsDotfile(rec state.Dotfile) bool {
+	if rec.Mode == config.ModeCopy {
+		return hashFile(rec.Dest) == rec.Hash
+	}
+	target, err := os.Readlink(rec.Dest)
+	return err == nil && target == rec.Source
+}
+
+// writeCopy writes content to dest with src's permissions
+func writeCopy(src, dest string, content []byte) error {
+	info, err := os.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("copy mode needs a file, %s is a directory", src)
+	}
+	return os.WriteFile(dest, content, info.Mode().Perm())
+}
+
+// hashFile returns the sha256 of path's content, or "" if it can't be read
+func hashFile(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return hashBytes(data)
+}
+
+func hashBytes(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
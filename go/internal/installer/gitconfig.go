@@ -0,0 +1,137 @@
+package installer
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/runner"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// gitCmd returns a git config --global command. Under a sandbox root it
+// points git at the root's .gitconfig, so the real one is never touched.
+func gitCmd(args ...string) runner.Cmd {
+	cmd := runner.Command("git", append([]string{"config", "--global"}, args...)...)
+	if paths.Sandboxed() {
+		cmd.Env = []string{"GIT_CONFIG_GLOBAL=" + filepath.Join(paths.Home(), ".gitconfig")}
+	}
+	return cmd
+}
+
+// gitGet returns a global git setting and whether it is set
+func gitGet(key string) (string, bool) {
+	out, err := run.Output(gitCmd("--get", key))
+	if err != nil {
+		return "", false
+	}
+	return strings.TrimSpace(string(out)), true
+}
+
+// gitSetCmd returns the command setting a global git setting
+func gitSetCmd(key, value string) runner.Cmd {
+	return gitCmd(key, value)
+}
+
+// gitConfigKeys returns app's git_config keys in order
+func gitConfigKeys(app config.App) []string {
+	keys := make([]string, 0, len(app.GitConfig))
+	for key := range app.GitConfig {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pendingGitConfig returns the git_config keys of app that need setting.
+// Settings changed by hand since boots applied them are reported in drifted
+// instead, and left alone.
+func pendingGitConfig(s *state.State, id string, app config.App) (pending, drifted []string) {
+	for _, key := range gitConfigKeys(app) {
+		current, _ := gitGet(key)
+		applied, ok := s.GitConfig[id][key]
+		switch {
+		case current == app.GitConfig[key]:
+		case ok && current != applied.Value:
+			drifted = append(drifted, key)
+		default:
+			pending = append(pending, key)
+		}
+	}
+	return pending, drifted
+}
+
+// applyGitConfig sets app's git_config in the global git config, recording
+// the values it replaces so uninstall can put them back
+func applyGitConfig(id string, app config.App, verbose bool) error {
+	if len(app.GitConfig) == 0 {
+		return nil
+	}
+
+	s, _ := state.Load()
+	pending, drifted := pendingGitConfig(s, id, app)
+	for _, key := range drifted {
+		LogWarn(fmt.Sprintf("%s: git %s was changed by hand, leaving it alone", id, key))
+	}
+
+	for _, key := range pending {
+		setting, ok := s.GitConfig[id][key]
+		if !ok {
+			// First time: remember what was there before
+			previous, set := gitGet(key)
+			setting = state.GitSetting{Previous: previous, Unset: !set}
+		}
+		setting.Value = app.GitConfig[key]
+
+		LogDim(fmt.Sprintf("git config --global %s %s", key, setting.Value))
+		if err := runCmd(verbose, gitSetCmd(key, setting.Value)); err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+		s.RecordGitSetting(id, key, setting)
+	}
+	return nil
+}
+
+// gitReverts returns the commands restoring the git settings boots applied
+// for id, and the keys left alone because they were changed by hand since
+func gitReverts(s *state.State, id string) (cmds []runner.Cmd, changed []string) {
+	settings := s.GitConfig[id]
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		setting := settings[key]
+		if current, _ := gitGet(key); current != setting.Value {
+			changed = append(changed, key)
+			continue
+		}
+		if setting.Unset {
+			cmds = append(cmds, gitCmd("--unset", key))
+		} else {
+			cmds = append(cmds, gitSetCmd(key, setting.Previous))
+		}
+	}
+	return cmds, changed
+}
+
+// revertGitConfig restores the git settings boots applied for id, unless
+// they were changed by hand since. It updates s without saving it.
+func revertGitConfig(s *state.State, id string, verbose bool) {
+	cmds, changed := gitReverts(s, id)
+	delete(s.GitConfig, id)
+
+	for _, key := range changed {
+		LogWarn(fmt.Sprintf("%s: git %s was changed by hand, leaving it", id, key))
+	}
+	for _, cmd := range cmds {
+		if err := runCmd(verbose, cmd); err != nil {
+			LogWarn(fmt.Sprintf("%s: reverting %s: %v", id, cmd, err))
+		}
+	}
+}
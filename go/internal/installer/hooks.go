@@ -0,0 +1,173 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/runner"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// runHooks runs an app's post_install commands and records the results in
+// state. Unless force is set, commands that succeeded on the last run are
+// not run again. With failFast the hooks after the first failure are
+// skipped.
+func runHooks(id string, app config.App, force, failFast, verbose bool) error {
+	s, _ := state.Load()
+	if len(app.PostInstall) == 0 {
+		// Drop results of hooks that have since been removed
+		s.ClearHooks(id)
+		return nil
+	}
+
+	done := make(map[string]bool)
+	if !force {
+		done = succeededHooks(s, id)
+	}
+
+	preamble := hookPreamble(app)
+	var results []state.HookResult
+	var errs []string
+	for _, hook := range app.PostInstall {
+		res := state.HookResult{Command: hook.Run}
+		if done[hook.Run] {
+			results = append(results, res)
+			continue
+		}
+		if failFast && len(errs) > 0 {
+			res.Skipped = true
+			results = append(results, res)
+			continue
+		}
+		if hookSatisfied(hook, preamble) {
+			LogDim(hook.Run + " (already done)")
+			results = append(results, res)
+			continue
+		}
+
+		LogDim(hook.Run)
+		if err := runCmd(verbose, zshCmd(preamble+hook.Run)); err != nil {
+			res.Error = err.Error()
+			errs = append(errs, fmt.Sprintf("%s: %v", hook.Run, err))
+		}
+		results = append(results, res)
+	}
+
+	s.RecordHooks(id, results)
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// hookPreamble returns the shell prefix for running app's commands: brew
+// shellenv, the app's env and path, and its init.zsh if it has one
+func hookPreamble(app config.App) string {
+	preamble := `eval "$(/opt/homebrew/bin/brew shellenv)" && `
+	shellInit := config.MergeShell(map[string]config.App{app.Name: {Env: app.Env, Path: app.Path}})
+	for _, line := range renderShellInit(shellInit, "zsh") {
+		preamble += line + " && "
+	}
+	initZsh := filepath.Join(app.Dir(paths.Packages()), "init.zsh")
+	if _, err := os.Stat(initZsh); err == nil {
+		preamble += fmt.Sprintf("source %s && ", initZsh)
+	}
+	return preamble
+}
+
+// zshCmd returns a command running script in zsh. Under a sandbox root
+// HOME is the root, so ~ in the script means what creates: guards check.
+func zshCmd(script string) runner.Cmd {
+	cmd := runner.Command("zsh", "-c", script)
+	if paths.Sandboxed() {
+		cmd.Env = []string{"HOME=" + paths.Home()}
+	}
+	return cmd
+}
+
+// hookSatisfied reports whether a hook's guards say it has nothing to do:
+// its creates path exists or its unless command succeeds
+func hookSatisfied(hook config.Hook, preamble string) bool {
+	if hook.Creates != "" {
+		if _, err := os.Stat(expandPath(hook.Creates)); err == nil {
+			return true
+		}
+	}
+	if hook.Unless != "" {
+		if _, err := run.Output(zshCmd(preamble + hook.Unless)); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// expandPath expands a leading ~ and environment variables in path
+func expandPath(path string) string {
+	if path == "~" || strings.HasPrefix(path, "~/") {
+		path = paths.Home() + path[1:]
+	}
+	return os.ExpandEnv(path)
+}
+
+// hookCommands returns the commands of app's post_install hooks
+func hookCommands(app config.App) []string {
+	commands := make([]string, len(app.PostInstall))
+	for i, hook := range app.PostInstall {
+		commands[i] = hook.Run
+	}
+	return commands
+}
+
+// succeededHooks returns the commands that succeeded on app id's last hook
+// run
+func succeededHooks(s *state.State, id string) map[string]bool {
+	done := make(map[string]bool)
+	for _, res := range s.Hooks[id].Results {
+		if res.Error == "" && !res.Skipped {
+			done[res.Command] = true
+		}
+	}
+	return done
+}
+
+// pendingHooks returns app's post_install commands that haven't succeeded
+// yet
+func pendingHooks(s *state.State, id string, app config.App) []config.Hook {
+	done := succeededHooks(s, id)
+	var pending []config.Hook
+	for _, hook := range app.PostInstall {
+		if !done[hook.Run] {
+			pending = append(pending, hook)
+		}
+	}
+	return pending
+}
+
+// RunHooks reruns the post_install hooks of apps. Apps without hooks are
+// skipped.
+func RunHooks(apps map[string]config.App, failFast, verbose bool) *Result {
+	result := newResult("hooks")
+
+	for _, id := range sortedKeys(apps) {
+		app := apps[id]
+		if len(app.PostInstall) == 0 {
+			runHooks(id, app, true, failFast, verbose) // clears stale results
+			result.Skipped = append(result.Skipped, id)
+			continue
+		}
+
+		LogProgress(fmt.Sprintf("Running hooks for %s...", id))
+		if err := runHooks(id, app, true, failFast, verbose); err != nil {
+			result.fail(id, PhaseHooks, err)
+			continue
+		}
+		result.Configured = append(result.Configured, id)
+	}
+
+	return result
+}
@@ -3,7 +3,6 @@ package installer
 import (
 	"fmt"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"sort"
 	"strings"
@@ -11,9 +10,19 @@ import (
 
 	"github.com/charmbracelet/lipgloss"
 	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/runner"
+	"github.com/schmoli/macos-setup/internal/settings"
 	"github.com/schmoli/macos-setup/internal/state"
 )
 
+// reexec replaces the process after a rebuild; tests stub it out
+var reexec = syscall.Exec
+
+// pulledEnv tells a re-exec'd boots that the previous process pulled, so
+// the new binary does the post-pull work like regenerating init files
+const pulledEnv = "BOOTS_PULLED"
+
 // Styled output helpers
 var (
 	progressStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
@@ -43,409 +52,500 @@ func LogDim(msg string) {
 	fmt.Println(dimStyle.Render("   " + msg))
 }
 
-// InstalledBrewPackages returns set of installed brew/cask packages
-func InstalledBrewPackages() map[string]bool {
-	installed := make(map[string]bool)
-
-	// Get brew formulae
-	cmd := exec.Command("/opt/homebrew/bin/brew", "list", "--formula", "-1")
-	if out, err := cmd.Output(); err == nil {
-		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-			if line != "" {
-				installed[line] = true
-			}
-		}
-	}
-
-	// Get casks
-	cmd = exec.Command("/opt/homebrew/bin/brew", "list", "--cask", "-1")
-	if out, err := cmd.Output(); err == nil {
-		for _, line := range strings.Split(strings.TrimSpace(string(out)), "\n") {
-			if line != "" {
-				installed[line] = true
-			}
-		}
-	}
+// Phases an app can fail in
+const (
+	PhaseResolve     = "resolve"    // no backend for the install type
+	PhaseDependency  = "dependency" // a dependency failed first
+	PhaseInstall     = "install"
+	PhaseUninstall   = "uninstall"
+	PhaseUpgrade     = "upgrade"
+	PhaseHooks       = "post_install"
+	PhaseConfig      = "config" // deploying the app's config file
+	PhaseGitConfig   = "git_config"
+	PhaseCompletions = "completions"
+)
 
-	return installed
+// Failure records why an app failed and in which phase
+type Failure struct {
+	App   string `json:"app"`
+	Phase string `json:"phase"`
+	Error string `json:"error"`
 }
 
-// GenerateBrewfile creates a temp Brewfile for the given apps
-func GenerateBrewfile(apps map[string]config.App) (string, error) {
-	var lines []string
-
-	for name, app := range apps {
-		pkg := name
-		if app.Package != "" {
-			pkg = app.Package
-		}
-
-		switch app.Install {
-		case "brew":
-			lines = append(lines, fmt.Sprintf("brew \"%s\"", pkg))
-		case "cask":
-			lines = append(lines, fmt.Sprintf("cask \"%s\"", pkg))
-		}
-	}
+// Result tracks install and remove outcomes
+type Result struct {
+	Action     string    `json:"action"`
+	Installed  []string  `json:"installed"`
+	Removed    []string  `json:"removed"`
+	Upgraded   []string  `json:"upgraded"`
+	Configured []string  `json:"configured"` // configured or hooks rerun
+	Skipped    []string  `json:"skipped"`
+	Failed     []string  `json:"-"`
+	Failures   []Failure `json:"failed"`
+}
 
-	if len(lines) == 0 {
-		return "", nil
-	}
+// Outcomes of a run, from Result.Status
+const (
+	StatusSuccess = "success" // nothing failed
+	StatusPartial = "partial" // some apps failed, others succeeded
+	StatusFailure = "failure" // every app failed
+)
 
-	tmpFile := "/tmp/boots-Brewfile"
-	content := strings.Join(lines, "\n") + "\n"
-	if err := os.WriteFile(tmpFile, []byte(content), 0644); err != nil {
-		return "", err
+func newResult(action string) *Result {
+	return &Result{
+		Action:     action,
+		Installed:  []string{},
+		Removed:    []string{},
+		Upgraded:   []string{},
+		Configured: []string{},
+		Skipped:    []string{},
+		Failures:   []Failure{},
 	}
-
-	return tmpFile, nil
 }
 
-// Result tracks install outcomes
-type Result struct {
-	Installed []string
-	Skipped   []string
-	Failed    []string
+// fail records id as failed in phase
+func (r *Result) fail(id, phase string, err error) {
+	r.Failed = append(r.Failed, id)
+	r.Failures = append(r.Failures, Failure{App: id, Phase: phase, Error: err.Error()})
 }
 
-// Install installs apps from the given map, using Brewfile for brew/cask
-func Install(apps map[string]config.App, verbose bool) (*Result, error) {
-	result := &Result{}
-	installed := InstalledBrewPackages()
-
-	// Separate by install type
-	brewApps := make(map[string]config.App)
-	npmApps := make(map[string]config.App)
-	masApps := make(map[string]config.App)
+// Status summarizes the result as success, partial or failure
+func (r *Result) Status() string {
+	switch {
+	case len(r.Failed) == 0:
+		return StatusSuccess
+	case len(r.Installed)+len(r.Removed)+len(r.Upgraded)+len(r.Configured)+len(r.Skipped) == 0:
+		return StatusFailure
+	default:
+		return StatusPartial
+	}
+}
 
-	for name, app := range apps {
-		pkg := name
-		if app.Package != "" {
-			pkg = app.Package
-		}
+// Install installs apps from the given map in dependency order, batching
+// each level by backend, then configures the newly installed apps. With
+// failFast an app's hooks stop at the first failure.
+func Install(apps map[string]config.App, failFast, verbose bool) (*Result, error) {
+	result := newResult("install")
 
-		// Check if already installed
-		if installed[pkg] || isNpmInstalled(pkg) {
-			result.Skipped = append(result.Skipped, name)
-			continue
-		}
+	levels, err := config.InstallOrder(apps)
+	if err != nil {
+		return result, err
+	}
 
-		switch app.Install {
-		case "brew", "cask":
-			brewApps[name] = app
-		case "npm":
-			npmApps[name] = app
-		case "mas":
-			masApps[name] = app
+	// Check what's already installed, per backend
+	groups, unsupported := groupByBackend(apps)
+	installed := make(map[string]bool)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			installed[id] = true
 		}
 	}
+	for _, id := range unsupported {
+		LogFail(fmt.Sprintf("Skipping %s: unsupported install type %q", id, apps[id].Install))
+	}
 
-	// Install brew/cask via Brewfile
-	if len(brewApps) > 0 {
-		if err := installBrewApps(brewApps, result, verbose); err != nil {
-			return result, err
-		}
+	failed := make(map[string]bool)
+	for _, id := range unsupported {
+		result.fail(id, PhaseResolve, fmt.Errorf("unsupported install type %q", apps[id].Install))
+		failed[id] = true
 	}
 
-	// Install npm apps sequentially
-	if len(npmApps) > 0 {
-		for name, app := range npmApps {
-			if err := installNpmApp(name, app, result, verbose); err != nil {
-				result.Failed = append(result.Failed, name)
+	for _, level := range levels {
+		pending := make(map[string]config.App)
+		for _, id := range level {
+			app := apps[id]
+			if failed[id] {
+				continue
+			}
+
+			// Skip apps whose dependencies failed
+			if dep := failedDependency(app, failed); dep != "" {
+				LogFail(fmt.Sprintf("Skipping %s: dependency %s failed", id, dep))
+				result.fail(id, PhaseDependency, fmt.Errorf("dependency %s failed", dep))
+				failed[id] = true
+				continue
 			}
-		}
-	}
 
-	// Install mas apps (interactive)
-	for name, app := range masApps {
-		if err := installMasApp(name, app, result, verbose); err != nil {
-			result.Failed = append(result.Failed, name)
+			if installed[id] {
+				result.Skipped = append(result.Skipped, id)
+				continue
+			}
+			pending[id] = app
+		}
+
+		levelGroups, _ := groupByBackend(pending)
+		for _, g := range levelGroups {
+			errs := g.backend.Install(g.apps, verbose)
+			for _, id := range sortedKeys(g.apps) {
+				if errs[id] != nil {
+					result.fail(id, PhaseInstall, errs[id])
+					failed[id] = true
+					continue
+				}
+				result.Installed = append(result.Installed, id)
+				trackInstalled(id)
+			}
 		}
 	}
 
 	// Post-install: run post_install hooks
-	for name, app := range apps {
-		if contains(result.Installed, name) {
-			configureApp(name, app)
+	for _, id := range result.Installed {
+		if phase, err := configureApp(id, apps[id], true, failFast, verbose); err != nil {
+			result.fail(id, phase, err)
 		}
 	}
 
 	// Ensure shell integration is set up
 	if len(result.Installed) > 0 {
-		EnsureShellIntegration()
+		RefreshShellIntegration()
 	}
 
 	return result, nil
 }
 
-func isNpmInstalled(pkg string) bool {
-	cmd := exec.Command("npm", "list", "-g", pkg)
-	return cmd.Run() == nil
-}
+// Remove uninstalls apps, drops them from state and regenerates init.zsh.
+// It refuses to remove an app another installed app depends on unless force
+// is set.
+func Remove(cfg *config.Config, apps map[string]config.App, force, verbose bool) (*Result, error) {
+	result := newResult("remove")
 
-func installBrewApps(apps map[string]config.App, result *Result, verbose bool) error {
-	brewfile, err := GenerateBrewfile(apps)
+	s, err := loadState(cfg)
 	if err != nil {
-		return err
+		return result, err
 	}
-	if brewfile == "" {
-		return nil
-	}
-	defer os.Remove(brewfile)
 
-	var names []string
-	for name := range apps {
-		names = append(names, name)
-	}
-	LogProgress(fmt.Sprintf("Installing %d packages...", len(names)))
-
-	cmd := exec.Command("/opt/homebrew/bin/brew", "bundle", "--file="+brewfile)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		// Some may have failed, but continue
-		LogWarn("brew bundle had errors")
-		if verbose {
-			LogFail(fmt.Sprintf("Command: brew bundle --file=%s", brewfile))
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				LogFail(fmt.Sprintf("Exit code: %d", exitErr.ExitCode()))
-			}
+	if !force {
+		if err := checkDependents(cfg, s, apps); err != nil {
+			return result, err
 		}
 	}
 
-	// Check what actually got installed
-	nowInstalled := InstalledBrewPackages()
-	for name, app := range apps {
-		pkg := name
-		if app.Package != "" {
-			pkg = app.Package
+	groups, unsupported := groupByBackend(apps)
+	for _, id := range unsupported {
+		LogFail(fmt.Sprintf("Skipping %s: unsupported install type %q", id, apps[id].Install))
+		result.fail(id, PhaseResolve, fmt.Errorf("unsupported install type %q", apps[id].Install))
+	}
+
+	for _, g := range groups {
+		installed := g.backend.Installed(g.apps)
+		present := make(map[string]config.App)
+		for _, id := range sortedKeys(g.apps) {
+			switch {
+			case installed[id]:
+				present[id] = g.apps[id]
+			case s.IsTracked(id):
+				// Gone already, just forget it
+				removeDotfile(s, id)
+				revertGitConfig(s, id, verbose)
+				removeCompletion(g.apps[id])
+				s.MarkRemoved(id)
+				result.Removed = append(result.Removed, id)
+			default:
+				result.Skipped = append(result.Skipped, id)
+			}
 		}
-		if nowInstalled[pkg] {
-			result.Installed = append(result.Installed, name)
-			trackInstalled(name)
-		} else {
-			result.Failed = append(result.Failed, name)
+		if len(present) == 0 {
+			continue
+		}
+
+		errs := g.backend.Uninstall(present, verbose)
+		for _, id := range sortedKeys(present) {
+			if errs[id] != nil {
+				result.fail(id, PhaseUninstall, errs[id])
+				continue
+			}
+			removeDotfile(s, id)
+			revertGitConfig(s, id, verbose)
+			removeCompletion(present[id])
+			s.MarkRemoved(id)
+			result.Removed = append(result.Removed, id)
 		}
 	}
 
-	return nil
+	if len(result.Removed) > 0 {
+		RefreshShellIntegration()
+	}
+
+	return result, nil
 }
 
-func installNpmApp(name string, app config.App, result *Result, verbose bool) error {
-	pkg := name
-	if app.Package != "" {
-		pkg = app.Package
-	}
-
-	LogProgress(fmt.Sprintf("Installing %s...", name))
-	cmd := exec.Command("npm", "install", "-g", pkg)
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if verbose {
-			LogFail(fmt.Sprintf("Command: npm install -g %s", pkg))
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				LogFail(fmt.Sprintf("Exit code: %d", exitErr.ExitCode()))
+// checkDependents returns an error naming installed apps that depend on any
+// of the apps about to be removed
+func checkDependents(cfg *config.Config, s *state.State, apps map[string]config.App) error {
+	// Find remaining apps that depend on a removal target
+	dependents := make(map[string][]string)
+	candidates := make(map[string]config.App)
+	for id, app := range cfg.Apps {
+		if _, removing := apps[id]; removing {
+			continue
+		}
+		for _, dep := range app.Depends {
+			if _, ok := apps[dep]; ok {
+				dependents[dep] = append(dependents[dep], id)
+				candidates[id] = app
 			}
 		}
-		return err
+	}
+	if len(dependents) == 0 {
+		return nil
+	}
+
+	installed := make(map[string]bool)
+	groups, _ := groupByBackend(candidates)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			installed[id] = true
+		}
 	}
 
-	result.Installed = append(result.Installed, name)
-	trackInstalled(name)
+	var problems []string
+	for _, target := range sortedKeys(apps) {
+		var users []string
+		for _, id := range dependents[target] {
+			if installed[id] || s.IsTracked(id) {
+				users = append(users, id)
+			}
+		}
+		if len(users) > 0 {
+			sort.Strings(users)
+			problems = append(problems, fmt.Sprintf("%s is needed by %s", target, strings.Join(users, ", ")))
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s (use --force to remove anyway)", strings.Join(problems, "; "))
+	}
 	return nil
 }
 
-func installMasApp(name string, app config.App, result *Result, verbose bool) error {
-	LogProgress(fmt.Sprintf("Installing %s from App Store...", name))
-	cmd := exec.Command("mas", "install", fmt.Sprintf("%d", app.ID))
-	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	if err := cmd.Run(); err != nil {
-		if verbose {
-			LogFail(fmt.Sprintf("Command: mas install %d", app.ID))
-			if exitErr, ok := err.(*exec.ExitError); ok {
-				LogFail(fmt.Sprintf("Exit code: %d", exitErr.ExitCode()))
-			}
+// failedDependency returns the first dependency of app that failed to install
+func failedDependency(app config.App, failed map[string]bool) string {
+	for _, dep := range app.Depends {
+		if failed[dep] {
+			return dep
 		}
-		return err
 	}
+	return ""
+}
 
-	result.Installed = append(result.Installed, name)
-	trackInstalled(name)
-	return nil
+func sortedKeys(apps map[string]config.App) []string {
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
-func trackInstalled(name string) {
+func trackInstalled(id string) {
 	if s, err := state.Load(); err == nil {
-		s.MarkInstalled(name)
+		s.MarkInstalled(id)
 	}
 }
 
-func contains(slice []string, item string) bool {
-	for _, s := range slice {
-		if s == item {
-			return true
-		}
+// loadState loads state, upgrading bare app names tracked by older versions
+// to their category-qualified IDs
+func loadState(cfg *config.Config) (*state.State, error) {
+	s, err := state.Load()
+	if err != nil {
+		return s, err
 	}
-	return false
-}
 
-func configureApp(name string, app config.App) {
-	home, _ := os.UserHomeDir()
+	if migrateState(cfg, s) {
+		s.Save() // best effort
+	}
 
-	// Run post_install commands
-	if len(app.PostInstall) > 0 {
-		// Build preamble: brew shellenv + app's init.zsh if exists
-		preamble := `eval "$(/opt/homebrew/bin/brew shellenv)" && `
-		initZsh := filepath.Join(home, ".config", "boots", "repo", "packages", app.Category, name, "init.zsh")
-		if _, err := os.Stat(initZsh); err == nil {
-			preamble += fmt.Sprintf("source %s && ", initZsh)
-		}
+	return s, nil
+}
 
-		for _, cmdStr := range app.PostInstall {
-			LogDim(cmdStr)
-			fullCmd := preamble + cmdStr
-			cmd := exec.Command("zsh", "-c", fullCmd)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+// migrateState renames bare app names in s to IDs without saving, and
+// reports whether anything changed
+func migrateState(cfg *config.Config, s *state.State) bool {
+	migrated := false
+	for name := range s.Installed {
+		if _, ok := cfg.Apps[name]; ok {
+			continue
 		}
+		if id, _, err := cfg.Lookup(name); err == nil && !s.IsTracked(id) {
+			s.Rename(name, id)
+			migrated = true
+		}
+	}
+	return migrated
+}
+
+// RefreshShellIntegration runs EnsureShellIntegration after apps changed,
+// warning rather than failing since the apps themselves are in place
+func RefreshShellIntegration() {
+	if err := EnsureShellIntegration(); err != nil {
+		LogWarn(fmt.Sprintf("Shell integration not updated: %v", err))
 	}
 }
 
-// EnsureShellIntegration ensures ~/.zshrc sources the repo init files
+// EnsureShellIntegration writes an init file for each shell in settings
+// and makes the shell's rc file source it
 func EnsureShellIntegration() error {
-	home, _ := os.UserHomeDir()
-	baseDir := filepath.Join(home, ".config", "boots")
-	repoDir := filepath.Join(baseDir, "repo")
-	packagesDir := filepath.Join(repoDir, "packages")
+	packagesDir := paths.Packages()
 
-	// Load state to get installed apps
-	s, err := state.Load()
+	// Load config to get app categories
+	cfg, err := config.Load(packagesDir)
 	if err != nil {
 		return err
 	}
 
-	// Load config to get app categories
-	cfg, err := config.Load(packagesDir)
+	// Load state to get installed apps
+	s, err := loadState(cfg)
+	if err != nil {
+		return err
+	}
+	set, err := settings.Load()
 	if err != nil {
 		return err
 	}
 
-	// Build init.zsh content with explicit source commands for installed apps only
-	var sources []string
-	for appName := range s.Installed {
-		app, ok := cfg.Apps[appName]
-		if !ok {
-			continue
+	installed := make(map[string]config.App)
+	for id := range s.Installed {
+		if app, ok := cfg.Apps[id]; ok {
+			installed[id] = app
 		}
+	}
 
-		initZshPath := filepath.Join(packagesDir, app.Category, appName, "init.zsh")
-		if _, err := os.Stat(initZshPath); err == nil {
-			// Use absolute path for reliability
-			sources = append(sources, fmt.Sprintf("source %s", initZshPath))
-		}
+	// Package env, path and aliases; the first app by ID wins a clash
+	shellInit := config.MergeShell(installed)
+	for _, c := range shellInit.Conflicts {
+		LogWarn(fmt.Sprintf("%s: %s, skipped", c.App, c))
 	}
 
-	initContent := `# boots shell integration (auto-generated)
+	var modified []string
+	for _, shell := range set.Shells() {
+		if _, ok := shellSyntaxes[shell]; !ok {
+			LogWarn(fmt.Sprintf("settings: unsupported shell %q (want %s)", shell, strings.Join(config.Shells, ", ")))
+			continue
+		}
+		if err := writeShellInit(shell, installed, shellInit); err != nil {
+			return err
+		}
+		for _, rcPath := range paths.RcFiles(shell) {
+			added, err := ensureRcSources(rcPath, shell)
+			if err != nil {
+				return err
+			}
+			if added {
+				modified = append(modified, rcPath)
+			}
+		}
+	}
 
-# Add boots to PATH
-export PATH="$HOME/.config/boots/bin:$PATH"
+	// Mark rc files modified
+	if len(modified) > 0 {
+		os.WriteFile(paths.RcMarker(), []byte(strings.Join(modified, "\n")+"\n"), 0644)
+	}
+	return nil
+}
 
-# mise initialization
-eval "$(mise activate zsh)"
+// writeShellInit writes the init file for shell: boots' own setup, the
+// package env, path and aliases, then source commands for the installed
+// apps' init.<shell> files
+func writeShellInit(shell string, installed map[string]config.App, shellInit config.ShellInit) error {
+	syntax := shellSyntaxes[shell]
 
-# Ensure compinit is loaded for completions
-autoload -Uz compinit && compinit -C
+	var sources []string
+	for _, id := range sortedKeys(installed) {
+		initPath := filepath.Join(installed[id].Dir(paths.Packages()), "init."+shell)
+		if _, err := os.Stat(initPath); err == nil {
+			// Use absolute path for reliability, guarded since a pull can
+			// delete the file before init files are regenerated
+			sources = append(sources, syntax.sourceIfExists(initPath))
+		}
+	}
 
-`
+	initContent := syntax.header() + "\n"
+	if !shellInit.Empty() {
+		initContent += "# Package env, path and aliases\n" + strings.Join(renderShellInit(shellInit, shell), "\n") + "\n\n"
+	}
 	if len(sources) > 0 {
 		initContent += strings.Join(sources, "\n") + "\n"
 	}
 
-	// Write init.zsh
-	initPath := filepath.Join(baseDir, "init.zsh")
-	if err := os.MkdirAll(baseDir, 0755); err != nil {
-		return err
-	}
-	if err := os.WriteFile(initPath, []byte(initContent), 0644); err != nil {
+	initPath := paths.Init(shell)
+	if err := os.MkdirAll(filepath.Dir(initPath), 0755); err != nil {
 		return err
 	}
+	return os.WriteFile(initPath, []byte(initContent), 0644)
+}
 
-	// Ensure .zshrc sources init.zsh (with conditional)
-	zshrcPath := filepath.Join(home, ".zshrc")
-	existing, _ := os.ReadFile(zshrcPath)
-	sourceLine := "[[ -f ~/.config/boots/init.zsh ]] && source ~/.config/boots/init.zsh"
-	if !strings.Contains(string(existing), sourceLine) {
-		f, err := os.OpenFile(zshrcPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
-		if err != nil {
-			return err
-		}
-		f.WriteString(fmt.Sprintf("\n# boots\n%s\n", sourceLine))
-		f.Close()
-
-		// Mark zshrc modified
-		markerPath := filepath.Join(baseDir, ".zshrc-modified")
-		os.WriteFile(markerPath, []byte{}, 0644)
+// ensureRcSources makes the rc file at rcPath source shell's init file,
+// reporting whether the line had to be added
+func ensureRcSources(rcPath, shell string) (bool, error) {
+	existing, _ := os.ReadFile(rcPath)
+	initRef := paths.Tilde(paths.Init(shell))
+	sourceLine := shellSyntaxes[shell].sourceIfExists(initRef)
+	if strings.Contains(string(existing), sourceLine) {
+		return false, nil
 	}
 
-	return nil
+	if err := os.MkdirAll(filepath.Dir(rcPath), 0755); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(rcPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintf(f, "\n# boots\n%s\n", sourceLine); err != nil {
+		return false, err
+	}
+	return true, nil
 }
 
-// CheckZshrcModified returns true if zshrc was modified, clears the marker
-func CheckZshrcModified() bool {
-	home, _ := os.UserHomeDir()
-	markerPath := filepath.Join(home, ".config", "boots", ".zshrc-modified")
-	if _, err := os.Stat(markerPath); err == nil {
-		os.Remove(markerPath)
-		return true
+// ModifiedRcFiles returns the rc files boots added itself to since the
+// last call, and clears the marker
+func ModifiedRcFiles() []string {
+	data, err := os.ReadFile(paths.RcMarker())
+	if err != nil {
+		return nil
 	}
-	return false
+	os.Remove(paths.RcMarker())
+	return strings.Fields(string(data))
 }
 
 // AutoPull fetches and pulls from origin if behind, returns true if pulled
 func AutoPull() bool {
-	home, _ := os.UserHomeDir()
-	repoDir := filepath.Join(home, ".config", "boots", "repo")
+	if os.Getenv(pulledEnv) != "" {
+		os.Unsetenv(pulledEnv)
+		return true
+	}
+	repoDir := paths.Repo()
+
+	git := func(args ...string) []byte {
+		cmd := runner.Command("git", args...)
+		cmd.Dir = repoDir
+		out, _ := run.Output(cmd)
+		return out
+	}
 
 	// Reset go files to avoid pull conflicts from go mod tidy
-	resetCmd := exec.Command("git", "checkout", "go/go.mod", "go/go.sum")
-	resetCmd.Dir = repoDir
-	resetCmd.Run()
+	git("checkout", "go/go.mod", "go/go.sum")
 
 	// Fetch
-	cmd := exec.Command("git", "fetch", "origin")
-	cmd.Dir = repoDir
-	cmd.Run()
+	git("fetch", "origin")
 
 	// Compare
-	localCmd := exec.Command("git", "rev-parse", "HEAD")
-	localCmd.Dir = repoDir
-	localHash, _ := localCmd.Output()
-
-	remoteCmd := exec.Command("git", "rev-parse", "origin/main")
-	remoteCmd.Dir = repoDir
-	remoteHash, _ := remoteCmd.Output()
+	localHash := git("rev-parse", "HEAD")
+	remoteHash := git("rev-parse", "origin/main")
 
 	if string(localHash) == string(remoteHash) {
 		return false
 	}
 
 	// Get changelog before pull
-	logCmd := exec.Command("git", "log", "HEAD..origin/main", "--oneline", "--format=%s")
-	logCmd.Dir = repoDir
-	logOutput, _ := logCmd.Output()
+	logOutput := git("log", "HEAD..origin/main", "--oneline", "--format=%s")
 
 	// Save old HEAD for diff check
 	oldHead := strings.TrimSpace(string(localHash))
 
 	// Pull silently
 	LogProgress("Pulling updates...")
-	cmd = exec.Command("git", "pull", "--rebase", "-q")
-	cmd.Dir = repoDir
-	cmd.Run()
+	git("pull", "--rebase", "-q")
 
 	// Display changelog
 	commits := strings.Split(strings.TrimSpace(string(logOutput)), "\n")
@@ -457,9 +557,7 @@ func AutoPull() bool {
 	}
 
 	// Check if Go files changed
-	diffCmd := exec.Command("git", "diff", "--name-only", oldHead, "HEAD")
-	diffCmd.Dir = repoDir
-	diffOutput, _ := diffCmd.Output()
+	diffOutput := git("diff", "--name-only", oldHead, "HEAD")
 
 	needsRebuild := false
 	for _, file := range strings.Split(string(diffOutput), "\n") {
@@ -475,114 +573,112 @@ func AutoPull() bool {
 
 	// Rebuild binary
 	LogProgress("Rebuilding...")
-	binary := filepath.Join(home, ".config", "boots", "bin", "boots")
-	buildCmd := exec.Command("go", "build", "-o", binary, "./cmd/macos-setup/")
+	binary := filepath.Join(paths.Bin(), "boots")
+	buildCmd := runner.Command("go", "build", "-o", binary, "./cmd/macos-setup/")
 	buildCmd.Dir = filepath.Join(repoDir, "go")
-	if err := buildCmd.Run(); err != nil {
+	if _, err := run.Output(buildCmd); err != nil {
 		LogFail("Rebuild failed: " + err.Error())
 		return true
 	}
 	LogSuccess("Rebuilt")
 
 	// Re-exec with new binary
-	syscall.Exec(binary, os.Args, os.Environ())
+	reexec(binary, os.Args, append(os.Environ(), pulledEnv+"=1"))
 	return true
 }
 
-// Upgrade upgrades all tracked apps
-func Upgrade(cfg *config.Config) error {
-	s, err := state.Load()
+// Upgrade upgrades all tracked apps. A backend that fails marks all of its
+// apps failed, since backends upgrade in one batch.
+func Upgrade(cfg *config.Config, verbose bool) (*Result, error) {
+	result := newResult("update")
+
+	s, err := loadState(cfg)
 	if err != nil {
-		return err
+		return result, err
 	}
 
 	if len(s.Installed) == 0 {
 		LogDim("No tracked apps to upgrade")
-		return nil
+		return result, nil
 	}
 
-	// Collect tracked brew/cask apps
-	var brewPkgs []string
-	var npmPkgs []string
-
-	for name := range s.Installed {
-		app, ok := cfg.Apps[name]
-		if !ok {
-			continue
-		}
-
-		pkg := name
-		if app.Package != "" {
-			pkg = app.Package
-		}
-
-		switch app.Install {
-		case "brew", "cask":
-			brewPkgs = append(brewPkgs, pkg)
-		case "npm":
-			npmPkgs = append(npmPkgs, pkg)
+	// Collect tracked apps
+	tracked := make(map[string]config.App)
+	for id := range s.Installed {
+		if app, ok := cfg.Apps[id]; ok {
+			tracked[id] = app
 		}
 	}
 
-	// Upgrade brew packages
-	if len(brewPkgs) > 0 {
-		LogProgress(fmt.Sprintf("Upgrading %d brew packages...", len(brewPkgs)))
-		args := append([]string{"upgrade"}, brewPkgs...)
-		cmd := exec.Command("/opt/homebrew/bin/brew", args...)
-		cmd.Stdout = os.Stdout
-		cmd.Stderr = os.Stderr
-		cmd.Run()
-	}
-
-	// Upgrade npm packages
-	if len(npmPkgs) > 0 {
-		LogProgress(fmt.Sprintf("Upgrading %d npm packages...", len(npmPkgs)))
-		for _, pkg := range npmPkgs {
-			cmd := exec.Command("npm", "update", "-g", pkg)
-			cmd.Stdout = os.Stdout
-			cmd.Stderr = os.Stderr
-			cmd.Run()
+	groups, _ := groupByBackend(tracked)
+	for _, g := range groups {
+		if err := g.backend.Upgrade(g.apps, verbose); err != nil {
+			LogFail(fmt.Sprintf("Upgrade failed: %v", err))
+			for _, id := range sortedKeys(g.apps) {
+				result.fail(id, PhaseUpgrade, err)
+			}
+		} else {
+			result.Upgraded = append(result.Upgraded, sortedKeys(g.apps)...)
 		}
+		refreshCompletions(g.apps)
 	}
 
-	LogSuccess("Upgrade complete")
-	return nil
+	if len(result.Failed) == 0 {
+		LogSuccess("Upgrade complete")
+	}
+	return result, nil
 }
 
-// Status prints installed apps in a styled table
-func Status(cfg *config.Config) {
-	installed := InstalledBrewPackages()
-
-	// Also check npm
-	npmInstalled := make(map[string]bool)
-	for name, app := range cfg.Apps {
-		if app.Install == "npm" {
-			pkg := name
-			if app.Package != "" {
-				pkg = app.Package
-			}
-			if isNpmInstalled(pkg) {
-				npmInstalled[name] = true
-			}
-		}
+// Update upgrades all tracked apps, then configures every installed app.
+// Apps Configure skips for not being installed are left out of the result.
+func Update(cfg *config.Config, failFast, verbose bool) (*Result, error) {
+	result, err := Upgrade(cfg, verbose)
+	if err != nil {
+		return result, err
 	}
 
-	// Collect installed apps by category
-	type appInfo struct {
-		name string
-		desc string
+	configured, err := Configure(cfg, cfg.Apps, failFast, verbose)
+	if err != nil {
+		return result, err
 	}
-	byCategory := make(map[string][]appInfo)
+	result.Configured = configured.Configured
+	result.Failed = append(result.Failed, configured.Failed...)
+	result.Failures = append(result.Failures, configured.Failures...)
+	return result, nil
+}
 
-	for name, app := range cfg.Apps {
-		pkg := name
-		if app.Package != "" {
-			pkg = app.Package
-		}
+// appInfo is an installed app as shown in status
+type appInfo struct {
+	name        string
+	desc        string
+	version     string
+	hooksFailed bool // last post_install run had failures
+	drifted     bool // deployed config file was changed locally
+	gitDrifted  bool // global git settings differ from git_config
+}
 
-		isInst := installed[pkg] || npmInstalled[name]
-		if isInst {
-			byCategory[app.Category] = append(byCategory[app.Category], appInfo{name, app.Description})
+// installedByCategory returns installed apps grouped by category, sorted
+// by name
+func installedByCategory(cfg *config.Config) map[string][]appInfo {
+	byCategory := make(map[string][]appInfo)
+	s, _ := state.Load()
+
+	groups, _ := groupByBackend(cfg.Apps)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			app := g.apps[id]
+			info := appInfo{
+				name:        app.Name,
+				desc:        app.Description,
+				version:     g.backend.Version(app),
+				hooksFailed: s.Hooks[id].Failed(hookCommands(app)),
+				drifted:     checkDotfile(app, s.Dotfiles[id]) == dotfileDrifted,
+			}
+			if s.IsTracked(id) {
+				pending, drifted := pendingGitConfig(s, id, app)
+				info.gitDrifted = len(pending)+len(drifted) > 0
+			}
+			byCategory[app.Category] = append(byCategory[app.Category], info)
 		}
 	}
 
@@ -593,6 +689,13 @@ func Status(cfg *config.Config) {
 		})
 	}
 
+	return byCategory
+}
+
+// Status prints installed apps in a styled table
+func Status(cfg *config.Config) {
+	byCategory := installedByCategory(cfg)
+
 	// Styles
 	headerStyle := lipgloss.NewStyle().
 		Bold(true).
@@ -607,27 +710,42 @@ func Status(cfg *config.Config) {
 	descStyle := lipgloss.NewStyle().
 		Foreground(lipgloss.Color("245"))
 
+	versionStyle := lipgloss.NewStyle().
+		Foreground(lipgloss.Color("240")).
+		PaddingLeft(2)
+
 	borderStyle := lipgloss.NewStyle().
 		Border(lipgloss.RoundedBorder()).
 		BorderForeground(lipgloss.Color("#0066FF")).
 		Padding(0, 1)
 
-	categories := []string{"cli", "apps", "docker", "git", "mas"}
 	var sections []string
 
-	for _, cat := range categories {
-		apps := byCategory[cat]
+	for _, cat := range cfg.VisibleCategories() {
+		apps := byCategory[cat.Name]
 		if len(apps) == 0 {
 			continue
 		}
 
 		// Header
-		header := headerStyle.Render(fmt.Sprintf("%s (%d)", cat, len(apps)))
+		header := headerStyle.Render(fmt.Sprintf("%s (%d)", cat.Title, len(apps)))
 
 		// Rows
 		var rows []string
 		for _, app := range apps {
 			row := nameStyle.Render(app.name) + descStyle.Render(app.desc)
+			if app.version != "" {
+				row += versionStyle.Render(app.version)
+			}
+			if app.hooksFailed {
+				row += warnStyle.Render("  post_install failed")
+			}
+			if app.drifted {
+				row += warnStyle.Render("  config changed locally")
+			}
+			if app.gitDrifted {
+				row += warnStyle.Render("  git config differs")
+			}
 			rows = append(rows, row)
 		}
 
@@ -0,0 +1,1188 @@
+package installer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/runner"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// setup points HOME at a temp dir and swaps in a fake runner with fresh
+// backends. Nothing is installed unless a test says otherwise.
+func setup(t *testing.T) *runner.Fake {
+	t.Helper()
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("BOOTS_HOME", "")
+
+	fake := &runner.Fake{}
+	prev := run
+	run = fake
+	registerDefaults()
+	t.Cleanup(func() {
+		run = prev
+		registerDefaults()
+	})
+	return fake
+}
+
+func testApp(category, name, install string) config.App {
+	return config.App{Name: name, Category: category, Install: install}
+}
+
+// npmListing returns npm ls --json output for the given packages
+func npmListing(pkgs ...string) string {
+	var deps []string
+	for _, pkg := range pkgs {
+		deps = append(deps, fmt.Sprintf(`%q: {"version": "1.0.0"}`, pkg))
+	}
+	return `{"dependencies": {` + strings.Join(deps, ", ") + `}}`
+}
+
+func indexOf(lines []string, prefix string) int {
+	for i, line := range lines {
+		if strings.HasPrefix(line, prefix) {
+			return i
+		}
+	}
+	return -1
+}
+
+func TestInstallPartialBrewBundleFailure(t *testing.T) {
+	fake := setup(t)
+	fake.OnFunc("brew bundle", func(runner.Cmd) (string, error) {
+		// k9s failed, the rest made it
+		fake.On("brew list --formula", "fzf 0.60.0\njq 1.7.1\n", nil)
+		return "", runner.Exit(1)
+	})
+
+	apps := map[string]config.App{
+		"cli/jq":  testApp("cli", "jq", "brew"),
+		"cli/fzf": testApp("cli", "fzf", "brew"),
+		"dev/k9s": testApp("dev", "k9s", "brew"),
+	}
+	result, err := Install(apps, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if want := []string{"cli/fzf", "cli/jq"}; !reflect.DeepEqual(result.Installed, want) {
+		t.Errorf("Installed = %v, want %v", result.Installed, want)
+	}
+	if want := []string{"dev/k9s"}; !reflect.DeepEqual(result.Failed, want) {
+		t.Errorf("Failed = %v, want %v", result.Failed, want)
+	}
+	want := []Failure{{App: "dev/k9s", Phase: PhaseInstall, Error: "not installed after brew bundle"}}
+	if !reflect.DeepEqual(result.Failures, want) {
+		t.Errorf("Failures = %+v, want %+v", result.Failures, want)
+	}
+	if result.Status() != StatusPartial {
+		t.Errorf("Status = %q, want %q", result.Status(), StatusPartial)
+	}
+
+	s, _ := state.Load()
+	if !s.IsTracked("cli/jq") || s.IsTracked("dev/k9s") {
+		t.Errorf("state = %v, want cli/jq tracked and dev/k9s not", s.Installed)
+	}
+}
+
+func TestReinstallRerunsHooks(t *testing.T) {
+	fake := setup(t)
+	fake.OnFunc("brew bundle", func(runner.Cmd) (string, error) {
+		fake.On("brew list --formula", "mise 2025.1.0\n", nil)
+		return "", nil
+	})
+
+	// mise's hook succeeded before it was uninstalled outside boots
+	app := testApp("cli", "mise", "brew")
+	app.PostInstall = []config.Hook{{Run: "mise use --global node@25"}}
+	s, _ := state.Load()
+	s.MarkInstalled("cli/mise")
+	s.RecordHooks("cli/mise", []state.HookResult{{Command: "mise use --global node@25"}})
+
+	if _, err := Install(map[string]config.App{"cli/mise": app}, false, false); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if !fake.Ran("zsh -c") {
+		t.Errorf("reinstall didn't rerun the hook: %v", fake.Lines())
+	}
+}
+
+func TestInstallSkipsInstalled(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "jq 1.7.1\n", nil)
+	fake.On("npm ls -g", npmListing("@anthropic-ai/claude-code"), nil)
+
+	claude := testApp("cli", "claude-code", "npm")
+	claude.Package = "@anthropic-ai/claude-code"
+	apps := map[string]config.App{
+		"cli/jq":          testApp("cli", "jq", "brew"),
+		"cli/claude-code": claude,
+	}
+	result, err := Install(apps, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if len(result.Installed) != 0 || len(result.Skipped) != 2 {
+		t.Errorf("result = %+v, want both skipped", result)
+	}
+	if fake.Ran("brew bundle") || fake.Ran("npm install") {
+		t.Errorf("ran install commands for installed apps: %v", fake.Lines())
+	}
+}
+
+func TestInstallDetectionIsPerBackend(t *testing.T) {
+	fake := setup(t)
+	// docker the formula and vercel the npm package are installed, but the
+	// docker cask and a brew vercel aren't
+	fake.On("brew list --formula", "docker 27.4.0\n", nil)
+	fake.On("npm ls -g", npmListing("vercel"), nil)
+
+	apps := map[string]config.App{
+		"apps/docker": testApp("apps", "docker", "cask"),
+		"cli/vercel":  testApp("cli", "vercel", "brew"),
+	}
+	plan, err := PlanInstall(apps)
+	if err != nil {
+		t.Fatalf("PlanInstall: %v", err)
+	}
+
+	if len(plan.Unchanged) != 0 {
+		t.Errorf("Unchanged = %v, want nothing detected", plan.Unchanged)
+	}
+	if len(plan.Steps) != 1 || !reflect.DeepEqual(plan.Steps[0].Apps, []string{"apps/docker", "cli/vercel"}) {
+		t.Errorf("steps = %+v, want both apps installed", plan.Steps)
+	}
+}
+
+func TestNpmListedOnce(t *testing.T) {
+	fake := setup(t)
+	fake.On("npm ls -g", `{"dependencies": {"vercel": {"version": "41.1.0"}, "aws-cdk": {"version": "2.170.0"}}}`, runner.Exit(1))
+
+	cfg := &config.Config{Apps: map[string]config.App{
+		"dev/vercel":  testApp("dev", "vercel", "npm"),
+		"dev/aws-cdk": testApp("dev", "aws-cdk", "npm"),
+	}}
+	if _, err := Install(cfg.Apps, false, false); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	got := installedByCategory(cfg)
+
+	want := []appInfo{{name: "aws-cdk", version: "2.170.0"}, {name: "vercel", version: "41.1.0"}}
+	if !reflect.DeepEqual(got["dev"], want) {
+		t.Errorf("dev = %+v, want %+v", got["dev"], want)
+	}
+	calls := 0
+	for _, line := range fake.Lines() {
+		if strings.HasPrefix(line, "npm ls") {
+			calls++
+		}
+	}
+	if calls != 1 {
+		t.Errorf("ran npm ls %d times, want 1: %v", calls, fake.Lines())
+	}
+}
+
+func TestInstallNpmFailure(t *testing.T) {
+	fake := setup(t)
+	fake.On("npm install -g broken", "", runner.Exit(1))
+
+	apps := map[string]config.App{
+		"cli/broken": testApp("cli", "broken", "npm"),
+		"cli/works":  testApp("cli", "works", "npm"),
+	}
+	result, err := Install(apps, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if want := []string{"cli/works"}; !reflect.DeepEqual(result.Installed, want) {
+		t.Errorf("Installed = %v, want %v", result.Installed, want)
+	}
+	if want := []string{"cli/broken"}; !reflect.DeepEqual(result.Failed, want) {
+		t.Errorf("Failed = %v, want %v", result.Failed, want)
+	}
+}
+
+func TestInstallMasIsInteractive(t *testing.T) {
+	fake := setup(t)
+
+	app := testApp("apps", "amphetamine", "mas")
+	app.ID = 937984704
+	if _, err := Install(map[string]config.App{"apps/amphetamine": app}, false, false); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	for _, c := range fake.Calls {
+		if c.String() == "mas install 937984704" {
+			if !c.Interactive {
+				t.Error("mas install should be interactive so it can prompt")
+			}
+			return
+		}
+	}
+	t.Errorf("mas install not run: %v", fake.Lines())
+}
+
+func TestInstallSkipsInstalledMas(t *testing.T) {
+	fake := setup(t)
+	fake.On("mas list", "937984704  Amphetamine  (5.3.2)\n497799835  Xcode  (16.0)\n", nil)
+
+	app := testApp("apps", "amphetamine", "mas")
+	app.ID = 937984704
+	result, err := Install(map[string]config.App{"apps/amphetamine": app}, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if want := []string{"apps/amphetamine"}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, want)
+	}
+	if fake.Ran("mas install") {
+		t.Errorf("ran mas install for an installed app: %v", fake.Lines())
+	}
+}
+
+func TestInstallDependencyOrder(t *testing.T) {
+	fake := setup(t)
+
+	cdk := testApp("dev", "aws-cdk", "npm")
+	cdk.Depends = []string{"cli/node-tools"}
+	apps := map[string]config.App{
+		"dev/aws-cdk":    cdk,
+		"cli/node-tools": testApp("cli", "node-tools", "npm"),
+	}
+	if _, err := Install(apps, false, false); err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	lines := fake.Lines()
+	dep, dependent := indexOf(lines, "npm install -g node-tools"), indexOf(lines, "npm install -g aws-cdk")
+	if dep < 0 || dependent < 0 || dep > dependent {
+		t.Errorf("dependency not installed first: %v", lines)
+	}
+}
+
+func TestInstallSkipsDependentsOfFailures(t *testing.T) {
+	fake := setup(t)
+	fake.On("npm install -g node-tools", "", runner.Exit(1))
+
+	cdk := testApp("dev", "aws-cdk", "npm")
+	cdk.Depends = []string{"cli/node-tools"}
+	apps := map[string]config.App{
+		"dev/aws-cdk":    cdk,
+		"cli/node-tools": testApp("cli", "node-tools", "npm"),
+	}
+	result, err := Install(apps, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+
+	if want := []string{"cli/node-tools", "dev/aws-cdk"}; !reflect.DeepEqual(result.Failed, want) {
+		t.Errorf("Failed = %v, want %v", result.Failed, want)
+	}
+	if fake.Ran("npm install -g aws-cdk") {
+		t.Error("installed aws-cdk although its dependency failed")
+	}
+	if f := result.Failures[1]; f.Phase != PhaseDependency || f.Error != "dependency cli/node-tools failed" {
+		t.Errorf("dev/aws-cdk failure = %+v", f)
+	}
+	if result.Status() != StatusFailure {
+		t.Errorf("Status = %q, want %q", result.Status(), StatusFailure)
+	}
+}
+
+func TestUpgrade(t *testing.T) {
+	fake := setup(t)
+
+	s, _ := state.Load()
+	s.MarkInstalled("cli/jq")
+	s.MarkInstalled("cli/claude-code")
+	s.MarkInstalled("apps/amphetamine")
+
+	claude := testApp("cli", "claude-code", "npm")
+	claude.Package = "@anthropic-ai/claude-code"
+	amphetamine := testApp("apps", "amphetamine", "mas")
+	amphetamine.ID = 937984704
+	cfg := &config.Config{Apps: map[string]config.App{
+		"cli/jq":           testApp("cli", "jq", "brew"),
+		"cli/fzf":          testApp("cli", "fzf", "brew"),
+		"cli/claude-code":  claude,
+		"apps/amphetamine": amphetamine,
+	}}
+	if _, err := Upgrade(cfg, false); err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+
+	for _, want := range []string{"brew upgrade jq", "npm update -g @anthropic-ai/claude-code", "mas upgrade 937984704"} {
+		if indexOf(fake.Lines(), want) < 0 {
+			t.Errorf("missing %q in %v", want, fake.Lines())
+		}
+	}
+	if fake.Ran("brew upgrade jq fzf") {
+		t.Error("upgraded untracked app")
+	}
+}
+
+func TestUpgradeFailureIsReported(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew upgrade", "", runner.Exit(1))
+
+	s, _ := state.Load()
+	s.MarkInstalled("cli/jq")
+	s.MarkInstalled("cli/claude-code")
+
+	claude := testApp("cli", "claude-code", "npm")
+	cfg := &config.Config{Apps: map[string]config.App{
+		"cli/jq":          testApp("cli", "jq", "brew"),
+		"cli/claude-code": claude,
+	}}
+	result, err := Upgrade(cfg, false)
+	if err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+
+	if want := []string{"cli/claude-code"}; !reflect.DeepEqual(result.Upgraded, want) {
+		t.Errorf("Upgraded = %v, want %v", result.Upgraded, want)
+	}
+	want := []Failure{{App: "cli/jq", Phase: PhaseUpgrade, Error: "exit status 1"}}
+	if !reflect.DeepEqual(result.Failures, want) {
+		t.Errorf("Failures = %+v, want %+v", result.Failures, want)
+	}
+	if result.Status() != StatusPartial {
+		t.Errorf("Status = %q, want %q", result.Status(), StatusPartial)
+	}
+}
+
+func TestInstalledByCategory(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "jq 1.7.1\n", nil)
+	fake.On("brew list --cask", "rectangle 0.85\n", nil)
+	fake.On("mas list", "937984704  Amphetamine  (5.3.2)\n", nil)
+
+	amphetamine := testApp("apps", "amphetamine", "mas")
+	amphetamine.ID = 937984704
+	cfg := &config.Config{Apps: map[string]config.App{
+		"cli/jq":           testApp("cli", "jq", "brew"),
+		"cli/fzf":          testApp("cli", "fzf", "brew"),
+		"apps/rectangle":   testApp("apps", "rectangle", "cask"),
+		"apps/amphetamine": amphetamine,
+	}}
+	got := installedByCategory(cfg)
+
+	want := map[string][]appInfo{
+		"cli":  {{name: "jq", version: "1.7.1"}},
+		"apps": {{name: "amphetamine", version: "5.3.2"}, {name: "rectangle", version: "0.85"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("installedByCategory = %+v, want %+v", got, want)
+	}
+}
+
+func TestAutoPullUpToDate(t *testing.T) {
+	fake := setup(t)
+	fake.On("git rev-parse", "abc123\n", nil)
+
+	if AutoPull() {
+		t.Error("AutoPull = true, want false when up to date")
+	}
+	if fake.Ran("git pull") {
+		t.Error("pulled although up to date")
+	}
+}
+
+func TestAutoPullRebuildsOnGoChanges(t *testing.T) {
+	fake := setup(t)
+	fake.On("git rev-parse HEAD", "abc123\n", nil)
+	fake.On("git rev-parse origin/main", "def456\n", nil)
+	fake.On("git log", "Add jq\n", nil)
+	fake.On("git diff", "packages/cli/jq/app.yaml\ngo/internal/config/config.go\n", nil)
+
+	var reexeced string
+	var reexecEnv []string
+	prev := reexec
+	reexec = func(argv0 string, argv []string, envv []string) error {
+		reexeced = argv0
+		reexecEnv = envv
+		return nil
+	}
+	defer func() { reexec = prev }()
+
+	if !AutoPull() {
+		t.Fatal("AutoPull = false, want true when behind")
+	}
+	if !fake.Ran("git pull --rebase") || !fake.Ran("go build") {
+		t.Errorf("want pull and rebuild, ran %v", fake.Lines())
+	}
+	if filepath.Base(reexeced) != "boots" {
+		t.Errorf("re-exec'd %q, want the rebuilt boots binary", reexeced)
+	}
+
+	// The new binary reports the pull, so main regenerates init files
+	if !slices.Contains(reexecEnv, pulledEnv+"=1") {
+		t.Fatalf("re-exec env lacks %s=1", pulledEnv)
+	}
+	t.Setenv(pulledEnv, "1")
+	fake.Calls = nil
+	if !AutoPull() {
+		t.Error("AutoPull after re-exec = false, want true")
+	}
+	if len(fake.Calls) > 0 || os.Getenv(pulledEnv) != "" {
+		t.Errorf("re-exec'd AutoPull ran %v with %s=%q, want nothing and the marker cleared", fake.Lines(), pulledEnv, os.Getenv(pulledEnv))
+	}
+}
+
+func TestAutoPullSkipsRebuildForPackages(t *testing.T) {
+	fake := setup(t)
+	fake.On("git rev-parse HEAD", "abc123\n", nil)
+	fake.On("git rev-parse origin/main", "def456\n", nil)
+	fake.On("git diff", "packages/cli/jq/app.yaml\n", nil)
+
+	if !AutoPull() {
+		t.Fatal("AutoPull = false, want true when behind")
+	}
+	if fake.Ran("go build") {
+		t.Error("rebuilt although only packages changed")
+	}
+}
+
+func TestRunHooksSourcesInitZsh(t *testing.T) {
+	fake := setup(t)
+
+	appDir := filepath.Join(paths.Packages(), "cli", "mise")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	initZsh := filepath.Join(appDir, "init.zsh")
+	if err := os.WriteFile(initZsh, []byte("eval \"$(mise activate zsh)\"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := testApp("cli", "mise", "brew")
+	app.PostInstall = []config.Hook{{Run: "mise use --global node@25"}}
+	if err := runHooks("cli/mise", app, true, false, false); err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+
+	if len(fake.Calls) != 1 {
+		t.Fatalf("ran %v, want one hook", fake.Lines())
+	}
+	script := fake.Calls[0].Args[len(fake.Calls[0].Args)-1]
+	if !strings.Contains(script, "source "+initZsh+" && mise use --global node@25") {
+		t.Errorf("hook script = %q, want init.zsh sourced before the command", script)
+	}
+}
+
+func TestRunHooksRecordsFailures(t *testing.T) {
+	fake := setup(t)
+	fake.OnFunc("zsh -c", func(c runner.Cmd) (string, error) {
+		if strings.HasSuffix(c.Args[len(c.Args)-1], "gh completion -s zsh > _gh") {
+			return "", runner.Exit(1)
+		}
+		return "", nil
+	})
+
+	app := testApp("git", "gh", "brew")
+	app.PostInstall = []config.Hook{{Run: "gh completion -s zsh > _gh"}, {Run: "gh extension install dlvhdr/gh-dash"}}
+	apps := map[string]config.App{"git/gh": app}
+
+	result := RunHooks(apps, false, false)
+	want := []Failure{{App: "git/gh", Phase: PhaseHooks, Error: "gh completion -s zsh > _gh: exit status 1"}}
+	if !reflect.DeepEqual(result.Failures, want) {
+		t.Errorf("Failures = %+v, want %+v", result.Failures, want)
+	}
+	if len(fake.Calls) != 2 {
+		t.Errorf("ran %v, want both hooks", fake.Lines())
+	}
+
+	s, _ := state.Load()
+	results := s.Hooks["git/gh"].Results
+	if len(results) != 2 || results[0].Error == "" || results[1].Error != "" {
+		t.Errorf("recorded %+v, want the first hook failed", results)
+	}
+
+	// With failFast the second hook is skipped
+	fake.Calls = nil
+	RunHooks(apps, true, false)
+	if len(fake.Calls) != 1 {
+		t.Errorf("ran %v, want only the failing hook", fake.Lines())
+	}
+	s, _ = state.Load()
+	if results := s.Hooks["git/gh"].Results; !results[1].Skipped {
+		t.Errorf("recorded %+v, want the second hook skipped", results)
+	}
+}
+
+func TestRunHooksGuards(t *testing.T) {
+	fake := setup(t)
+	fake.OnFunc("zsh -c", func(c runner.Cmd) (string, error) {
+		if strings.HasSuffix(c.Args[len(c.Args)-1], "test -n configured") {
+			return "", nil
+		}
+		if strings.HasSuffix(c.Args[len(c.Args)-1], "test -n missing") {
+			return "", runner.Exit(1)
+		}
+		return "", nil
+	})
+	if err := os.WriteFile(filepath.Join(paths.Home(), "_k9s"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	app := testApp("dev", "k9s", "brew")
+	app.PostInstall = []config.Hook{
+		{Run: "k9s completion zsh > ~/_k9s", Creates: "~/_k9s"},
+		{Run: "k9s config set a", Unless: "test -n configured"},
+		{Run: "k9s config set b", Unless: "test -n missing"},
+	}
+	if err := runHooks("dev/k9s", app, true, false, false); err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+
+	lines := fake.Lines()
+	for _, line := range lines {
+		if strings.Contains(line, "k9s completion") || strings.Contains(line, "k9s config set a") {
+			t.Errorf("ran guarded hook: %s", line)
+		}
+	}
+	if len(lines) == 0 || !strings.HasSuffix(lines[len(lines)-1], "k9s config set b") {
+		t.Errorf("ran %v, want the last hook", lines)
+	}
+}
+
+func TestRunHooksUnderRootUseRootHome(t *testing.T) {
+	fake := setup(t)
+	root := t.TempDir()
+	paths.SetRoot(root)
+	defer paths.SetRoot("")
+
+	app := testApp("dev", "k9s", "brew")
+	app.PostInstall = []config.Hook{{Run: "k9s info > ~/k9s.txt", Creates: "~/k9s.txt", Unless: "test -f ~/.k9s"}}
+	if err := runHooks("dev/k9s", app, true, false, false); err != nil {
+		t.Fatalf("runHooks: %v", err)
+	}
+
+	if len(fake.Calls) == 0 {
+		t.Fatal("no commands ran")
+	}
+	for _, c := range fake.Calls {
+		if !reflect.DeepEqual(c.Env, []string{"HOME=" + root}) {
+			t.Errorf("%s ran with env %v, want HOME=%s", c, c.Env, root)
+		}
+	}
+}
+
+func TestRemovedHookFailuresDontCount(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "gh 2.63.0\n", nil)
+
+	s, _ := state.Load()
+	s.MarkInstalled("git/gh")
+	s.RecordHooks("git/gh", []state.HookResult{{Command: "gh completion -s zsh > ~/_gh", Error: "exit status 1"}})
+
+	// The hook moved to completions:, so its old failure is stale
+	gh := testApp("git", "gh", "brew")
+	cfg := &config.Config{Apps: map[string]config.App{"git/gh": gh}}
+	if got := installedByCategory(cfg)["git"]; len(got) != 1 || got[0].hooksFailed {
+		t.Errorf("status = %+v, want gh without hook failures", got)
+	}
+
+	RunHooks(cfg.Apps, false, false)
+	if s, _ := state.Load(); len(s.Hooks) != 0 {
+		t.Errorf("state still records hooks: %v", s.Hooks)
+	}
+}
+
+func TestConfigureAdoptsAndIsIdempotent(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "git-delta 0.18.2\n", nil)
+
+	delta := testApp("git", "git-delta", "brew")
+	delta.PostInstall = []config.Hook{{Run: "git config --global core.pager delta"}}
+	cfg := &config.Config{Apps: map[string]config.App{
+		"git/git-delta": delta,
+		"cli/jq":        testApp("cli", "jq", "brew"),
+	}}
+
+	result, err := Configure(cfg, cfg.Apps, false, false)
+	if err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if want := []string{"git/git-delta"}; !reflect.DeepEqual(result.Configured, want) {
+		t.Errorf("Configured = %v, want %v", result.Configured, want)
+	}
+	if want := []string{"cli/jq"}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, want)
+	}
+	if s, _ := state.Load(); !s.IsTracked("git/git-delta") {
+		t.Error("installed app wasn't adopted into state")
+	}
+	if !fake.Ran("zsh -c") {
+		t.Fatalf("hook not run: %v", fake.Lines())
+	}
+
+	// Hooks that succeeded don't run again
+	fake.Calls = nil
+	if _, err := Configure(cfg, cfg.Apps, false, false); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if fake.Ran("zsh -c") {
+		t.Errorf("second run reran hooks: %v", fake.Lines())
+	}
+}
+
+// writeConfigApp creates an app shipping tmux.conf and returns it
+func writeConfigApp(t *testing.T, mode string) config.App {
+	t.Helper()
+	app := testApp("cli", "tmux", "brew")
+	app.Config = &config.AppConfig{Source: "tmux.conf", Dest: "~/.tmux.conf", Mode: mode}
+	dir := app.Dir(paths.Packages())
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "tmux.conf"), []byte("set -g mouse on\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return app
+}
+
+func TestDotfileSymlinkBackupAndRemove(t *testing.T) {
+	setup(t)
+	app := writeConfigApp(t, "")
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+	if err := os.WriteFile(dest, []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	if target, _ := os.Readlink(dest); target != filepath.Join(app.Dir(paths.Packages()), "tmux.conf") {
+		t.Errorf("%s -> %q, want a link to the package file", dest, target)
+	}
+	if data, _ := os.ReadFile(dest + backupSuffix); string(data) != "mine\n" {
+		t.Errorf("backup = %q, want the previous file", data)
+	}
+
+	s, _ := state.Load()
+	if got := checkDotfile(app, s.Dotfiles["cli/tmux"]); got != dotfileDeployed {
+		t.Errorf("checkDotfile = %q, want %q", got, dotfileDeployed)
+	}
+
+	removeDotfile(s, "cli/tmux")
+	if data, _ := os.ReadFile(dest); string(data) != "mine\n" {
+		t.Errorf("after remove %s = %q, want the backup restored", dest, data)
+	}
+	if _, ok := s.Dotfiles["cli/tmux"]; ok {
+		t.Error("state still records the dotfile")
+	}
+}
+
+func TestDotfileModeSwitchKeepsBackup(t *testing.T) {
+	setup(t)
+	app := writeConfigApp(t, "")
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+	if err := os.WriteFile(dest, []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+
+	// Switching to a template redeploys as a copy over our own symlink
+	app.Config.Template = true
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile after mode switch: %v", err)
+	}
+	if info, _ := os.Lstat(dest); info == nil || info.Mode()&os.ModeSymlink != 0 {
+		t.Fatalf("%s should be a copy after the switch", dest)
+	}
+
+	// A deleted copy is redeployed with the backup still recorded
+	os.Remove(dest)
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile after delete: %v", err)
+	}
+
+	s, _ := state.Load()
+	if got := s.Dotfiles["cli/tmux"].Backup; got != dest+backupSuffix {
+		t.Errorf("backup = %q, want %q", got, dest+backupSuffix)
+	}
+	if data, _ := os.ReadFile(dest + backupSuffix); string(data) != "mine\n" {
+		t.Errorf("backup = %q, want the user's original", data)
+	}
+	removeDotfile(s, "cli/tmux")
+	if data, _ := os.ReadFile(dest); string(data) != "mine\n" {
+		t.Errorf("after remove %s = %q, want the original restored", dest, data)
+	}
+}
+
+func TestDotfileSymlinkFollowsRenamedSource(t *testing.T) {
+	setup(t)
+	app := writeConfigApp(t, "")
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+
+	// The package renames its config file
+	dir := app.Dir(paths.Packages())
+	if err := os.Rename(filepath.Join(dir, "tmux.conf"), filepath.Join(dir, "tmux.conf.new")); err != nil {
+		t.Fatal(err)
+	}
+	app.Config.Source = "tmux.conf.new"
+
+	s, _ := state.Load()
+	if got := checkDotfile(app, s.Dotfiles["cli/tmux"]); got != dotfileOutdated {
+		t.Errorf("checkDotfile = %q, want %q", got, dotfileOutdated)
+	}
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	if target, _ := os.Readlink(dest); target != filepath.Join(dir, "tmux.conf.new") {
+		t.Errorf("%s -> %q, want the renamed source", dest, target)
+	}
+	if _, err := os.Lstat(dest + backupSuffix); err == nil {
+		t.Error("backed up boots' own link")
+	}
+}
+
+func TestDotfileCopyDrift(t *testing.T) {
+	setup(t)
+	app := writeConfigApp(t, "copy")
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "set -g mouse on\n" {
+		t.Fatalf("%s = %q, want a copy", dest, data)
+	}
+
+	// Local edits are reported and survive a redeploy and a remove
+	if err := os.WriteFile(dest, []byte("edited\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s, _ := state.Load()
+	if got := checkDotfile(app, s.Dotfiles["cli/tmux"]); got != dotfileDrifted {
+		t.Errorf("checkDotfile = %q, want %q", got, dotfileDrifted)
+	}
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	removeDotfile(s, "cli/tmux")
+	if data, _ := os.ReadFile(dest); string(data) != "edited\n" {
+		t.Errorf("%s = %q, want local edits kept", dest, data)
+	}
+}
+
+func TestDotfileTemplateRerendersOnSettingsChange(t *testing.T) {
+	setup(t)
+	t.Setenv("HOMEBREW_PREFIX", "/opt/homebrew")
+	app := writeConfigApp(t, "")
+	app.Config.Template = true
+	src := filepath.Join(app.Dir(paths.Packages()), "tmux.conf")
+	if err := os.WriteFile(src, []byte("# {{ .Profile }}\nset -g default-shell {{ .BrewPrefix }}/bin/zsh\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	writeSettings := func(profile string) {
+		t.Helper()
+		if err := os.MkdirAll(paths.Boots(), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(paths.Settings(), []byte("profile: "+profile+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+
+	writeSettings("work")
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); string(data) != "# work\nset -g default-shell /opt/homebrew/bin/zsh\n" {
+		t.Errorf("rendered %q", data)
+	}
+
+	writeSettings("home")
+	s, _ := state.Load()
+	if got := checkDotfile(app, s.Dotfiles["cli/tmux"]); got != dotfileOutdated {
+		t.Errorf("checkDotfile = %q, want %q after the profile changed", got, dotfileOutdated)
+	}
+	if err := deployDotfile("cli/tmux", app); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+	if data, _ := os.ReadFile(dest); !strings.HasPrefix(string(data), "# home\n") {
+		t.Errorf("re-rendered %q, want the new profile", data)
+	}
+}
+
+func TestCompletionsLifecycle(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "gh 2.63.0\n", nil)
+	fake.On("zsh -c", "#compdef gh\n", nil)
+
+	gh := testApp("git", "gh", "brew")
+	gh.Completions = "gh completion -s zsh"
+	cfg := &config.Config{Apps: map[string]config.App{"git/gh": gh}}
+	file := filepath.Join(paths.Completions(), "_gh")
+
+	if _, err := Configure(cfg, cfg.Apps, false, false); err != nil {
+		t.Fatalf("Configure: %v", err)
+	}
+	if data, _ := os.ReadFile(file); string(data) != "#compdef gh\n" {
+		t.Fatalf("%s = %q, want the generated completion", file, data)
+	}
+
+	// Upgrades regenerate, even though the file exists
+	fake.Calls = nil
+	if _, err := Upgrade(cfg, false); err != nil {
+		t.Fatalf("Upgrade: %v", err)
+	}
+	if !strings.HasSuffix(fake.Lines()[len(fake.Lines())-1], "gh completion -s zsh") {
+		t.Errorf("upgrade didn't regenerate completions: %v", fake.Lines())
+	}
+
+	if _, err := Remove(cfg, cfg.Apps, false, false); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if _, err := os.Stat(file); err == nil {
+		t.Error("completion left behind after remove")
+	}
+}
+
+// fakeGitConfig scripts git config --global against an in-memory store
+func fakeGitConfig(fake *runner.Fake, store map[string]string) {
+	fake.OnFunc("git config --global", func(c runner.Cmd) (string, error) {
+		args := c.Args[2:]
+		switch {
+		case args[0] == "--get":
+			if value, ok := store[args[1]]; ok {
+				return value + "\n", nil
+			}
+			return "", runner.Exit(1)
+		case args[0] == "--unset":
+			delete(store, args[1])
+		default:
+			store[args[0]] = args[1]
+		}
+		return "", nil
+	})
+}
+
+func TestGitConfigApplyDriftAndRevert(t *testing.T) {
+	fake := setup(t)
+	store := map[string]string{"core.pager": "less"}
+	fakeGitConfig(fake, store)
+
+	app := testApp("git", "git-delta", "brew")
+	app.GitConfig = map[string]string{"core.pager": "delta", "delta.navigate": "true", "merge.conflictStyle": "zdiff3"}
+
+	if err := applyGitConfig("git/git-delta", app, false); err != nil {
+		t.Fatalf("applyGitConfig: %v", err)
+	}
+	if store["core.pager"] != "delta" || store["merge.conflictStyle"] != "zdiff3" {
+		t.Fatalf("git config = %v, want the app's settings", store)
+	}
+
+	// Applying again changes nothing
+	fake.Calls = nil
+	if err := applyGitConfig("git/git-delta", app, false); err != nil {
+		t.Fatalf("applyGitConfig: %v", err)
+	}
+	if fake.Ran("git config --global core.pager") {
+		t.Errorf("reapplied unchanged settings: %v", fake.Lines())
+	}
+
+	// A hand-edited setting is reported and left alone, by apply and revert
+	store["delta.navigate"] = "false"
+	s, _ := state.Load()
+	if pending, drifted := pendingGitConfig(s, "git/git-delta", app); len(pending) != 0 || !reflect.DeepEqual(drifted, []string{"delta.navigate"}) {
+		t.Errorf("pending %v, drifted %v, want delta.navigate drifted", pending, drifted)
+	}
+	if err := applyGitConfig("git/git-delta", app, false); err != nil {
+		t.Fatalf("applyGitConfig: %v", err)
+	}
+
+	revertGitConfig(s, "git/git-delta", false)
+	want := map[string]string{"core.pager": "less", "delta.navigate": "false"}
+	if !reflect.DeepEqual(store, want) {
+		t.Errorf("after revert git config = %v, want %v", store, want)
+	}
+	if _, ok := s.GitConfig["git/git-delta"]; ok {
+		t.Error("state still records the git settings")
+	}
+}
+
+func TestGitConfigUnderRoot(t *testing.T) {
+	fake := setup(t)
+	fakeGitConfig(fake, map[string]string{})
+	root := t.TempDir()
+	paths.SetRoot(root)
+	defer paths.SetRoot("")
+
+	app := testApp("git", "git-delta", "brew")
+	app.GitConfig = map[string]string{"core.pager": "delta"}
+	if err := applyGitConfig("git/git-delta", app, false); err != nil {
+		t.Fatalf("applyGitConfig: %v", err)
+	}
+
+	want := []string{"GIT_CONFIG_GLOBAL=" + filepath.Join(root, ".gitconfig")}
+	for _, c := range fake.Calls {
+		if !reflect.DeepEqual(c.Env, want) {
+			t.Errorf("%s ran with env %v, want %v", c, c.Env, want)
+		}
+	}
+}
+
+func TestEnsureShellIntegrationUnderRoot(t *testing.T) {
+	setup(t)
+	root := t.TempDir()
+	paths.SetRoot(root)
+	defer paths.SetRoot("")
+
+	appDir := filepath.Join(paths.Packages(), "cli", "zoxide")
+	os.MkdirAll(appDir, 0755)
+	os.WriteFile(filepath.Join(appDir, "app.yaml"), []byte("install: brew\ndescription: Smarter cd\n"), 0644)
+	os.WriteFile(filepath.Join(appDir, "init.zsh"), []byte("eval \"$(zoxide init zsh)\"\n"), 0644)
+	s, _ := state.Load()
+	s.MarkInstalled("cli/zoxide")
+
+	if err := EnsureShellIntegration(); err != nil {
+		t.Fatalf("EnsureShellIntegration: %v", err)
+	}
+
+	initZsh, err := os.ReadFile(filepath.Join(root, ".config", "boots", "init.zsh"))
+	if err != nil {
+		t.Fatalf("init.zsh not written under root: %v", err)
+	}
+	initFile := filepath.Join(appDir, "init.zsh")
+	if !strings.Contains(string(initZsh), "[[ -f "+initFile+" ]] && source "+initFile) {
+		t.Errorf("init.zsh doesn't source zoxide:\n%s", initZsh)
+	}
+	fpath := strings.Index(string(initZsh), "fpath=("+paths.Completions())
+	if fpath < 0 || fpath > strings.Index(string(initZsh), "compinit -C") {
+		t.Errorf("init.zsh should add the completions dir to fpath before compinit:\n%s", initZsh)
+	}
+
+	zshrc, err := os.ReadFile(filepath.Join(root, ".zshrc"))
+	if err != nil {
+		t.Fatalf(".zshrc not written under root: %v", err)
+	}
+	if !strings.Contains(string(zshrc), "source "+paths.Init("zsh")) {
+		t.Errorf(".zshrc should source the sandboxed init.zsh by absolute path:\n%s", zshrc)
+	}
+}
+
+func TestEnsureShellIntegrationRendersEnvAndAliases(t *testing.T) {
+	setup(t)
+	root := t.TempDir()
+	paths.SetRoot(root)
+	defer paths.SetRoot("")
+
+	packages := map[string]string{
+		"cli/bat":  "install: brew\ndescription: Cat clone\nenv:\n  MANPAGER: bat -plman\naliases:\n  cat: bat --paging=never\n",
+		"cli/ccat": "install: brew\ndescription: Colorizing cat\naliases:\n  cat: ccat\n  home: echo \"it's $HOME\"\n",
+		"cli/mine": "install: brew\ndescription: My tools\npath:\n  - ~/bin\n",
+	}
+	s, _ := state.Load()
+	for id, yaml := range packages {
+		appDir := filepath.Join(paths.Packages(), id)
+		os.MkdirAll(appDir, 0755)
+		os.WriteFile(filepath.Join(appDir, "app.yaml"), []byte(yaml), 0644)
+		s.MarkInstalled(id)
+	}
+
+	if err := EnsureShellIntegration(); err != nil {
+		t.Fatalf("EnsureShellIntegration: %v", err)
+	}
+	initZsh, _ := os.ReadFile(paths.Init("zsh"))
+
+	for _, line := range []string{
+		`export MANPAGER="bat -plman"`,
+		`export PATH="$HOME/bin:$PATH"`,
+		`alias cat='bat --paging=never'`,
+		`alias home='echo "it'\''s $HOME"'`,
+	} {
+		if !strings.Contains(string(initZsh), "\n"+line+"\n") {
+			t.Errorf("init.zsh is missing %s:\n%s", line, initZsh)
+		}
+	}
+	if strings.Contains(string(initZsh), "alias cat='ccat'") {
+		t.Errorf("conflicting alias from cli/ccat should be skipped:\n%s", initZsh)
+	}
+}
+
+func TestEnsureShellIntegrationBashAndFish(t *testing.T) {
+	setup(t)
+	root := t.TempDir()
+	paths.SetRoot(root)
+	defer paths.SetRoot("")
+
+	appDir := filepath.Join(paths.Packages(), "cli", "bat")
+	os.MkdirAll(appDir, 0755)
+	os.WriteFile(filepath.Join(appDir, "app.yaml"), []byte("install: brew\ndescription: Cat clone\nenv:\n  MANPAGER: bat -plman\npath:\n  - ~/bin\naliases:\n  cat: bat --paging=never\n"), 0644)
+	os.WriteFile(filepath.Join(appDir, "init.fish"), []byte("bat --version > /dev/null\n"), 0644)
+	os.MkdirAll(paths.Boots(), 0755)
+	os.WriteFile(paths.Settings(), []byte("shell: [bash, fish]\n"), 0644)
+	s, _ := state.Load()
+	s.MarkInstalled("cli/bat")
+
+	if err := EnsureShellIntegration(); err != nil {
+		t.Fatalf("EnsureShellIntegration: %v", err)
+	}
+
+	initBash, _ := os.ReadFile(paths.Init("bash"))
+	for _, line := range []string{`export MANPAGER="bat -plman"`, `alias cat='bat --paging=never'`, "complete -F _boots boots"} {
+		if !strings.Contains(string(initBash), line) {
+			t.Errorf("init.bash is missing %s:\n%s", line, initBash)
+		}
+	}
+
+	initFish, _ := os.ReadFile(paths.Init("fish"))
+	for _, line := range []string{
+		`set -gx MANPAGER "bat -plman"`,
+		`set -gx PATH "$HOME/bin" $PATH`,
+		`alias cat 'bat --paging=never'`,
+		"test -f " + filepath.Join(appDir, "init.fish") + "; and source " + filepath.Join(appDir, "init.fish"),
+	} {
+		if !strings.Contains(string(initFish), line) {
+			t.Errorf("init.fish is missing %s:\n%s", line, initFish)
+		}
+	}
+
+	fishConfig, _ := os.ReadFile(filepath.Join(root, ".config", "fish", "config.fish"))
+	if !strings.Contains(string(fishConfig), "test -f "+paths.Init("fish")+"; and source "+paths.Init("fish")) {
+		t.Errorf("config.fish should source init.fish:\n%s", fishConfig)
+	}
+	if _, err := os.Stat(filepath.Join(root, ".zshrc")); err == nil {
+		t.Error(".zshrc written although zsh isn't in the shell setting")
+	}
+
+	want := []string{filepath.Join(root, ".bash_profile"), filepath.Join(root, ".bashrc"), filepath.Join(root, ".config", "fish", "config.fish")}
+	if got := ModifiedRcFiles(); !reflect.DeepEqual(got, want) {
+		t.Errorf("ModifiedRcFiles() = %v, want %v", got, want)
+	}
+	if err := EnsureShellIntegration(); err != nil {
+		t.Fatalf("EnsureShellIntegration: %v", err)
+	}
+	if got := ModifiedRcFiles(); got != nil {
+		t.Errorf("rc files modified again: %v", got)
+	}
+}
+
+func TestRemove(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "jq 1.7.1\n", nil)
+	fake.On("brew list --cask", "rectangle 0.85\n", nil)
+
+	s, _ := state.Load()
+	s.MarkInstalled("cli/jq")
+	s.MarkInstalled("apps/rectangle")
+
+	cfg := &config.Config{Apps: map[string]config.App{
+		"cli/jq":         testApp("cli", "jq", "brew"),
+		"cli/fzf":        testApp("cli", "fzf", "brew"),
+		"apps/rectangle": testApp("apps", "rectangle", "cask"),
+	}}
+	result, err := Remove(cfg, cfg.Apps, false, false)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+
+	if want := []string{"apps/rectangle", "cli/jq"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Errorf("Removed = %v, want %v", result.Removed, want)
+	}
+	if want := []string{"cli/fzf"}; !reflect.DeepEqual(result.Skipped, want) {
+		t.Errorf("Skipped = %v, want %v", result.Skipped, want)
+	}
+	for _, want := range []string{"brew uninstall --cask rectangle", "brew uninstall jq"} {
+		if !fake.Ran(want) {
+			t.Errorf("missing %q in %v", want, fake.Lines())
+		}
+	}
+
+	s, _ = state.Load()
+	if len(s.Installed) != 0 {
+		t.Errorf("state still tracks %v", s.Installed)
+	}
+}
+
+func TestShellAppLifecycle(t *testing.T) {
+	fake := setup(t)
+
+	prompt := testApp("shell", "prompt", "shell")
+	prompt.Description = "Prompt setup"
+	cfg := &config.Config{Apps: map[string]config.App{"shell/prompt": prompt}}
+
+	if got := installedByCategory(cfg); len(got) != 0 {
+		t.Errorf("installedByCategory before install = %+v, want none", got)
+	}
+
+	result, err := Install(cfg.Apps, false, false)
+	if err != nil {
+		t.Fatalf("Install: %v", err)
+	}
+	if want := []string{"shell/prompt"}; !reflect.DeepEqual(result.Installed, want) {
+		t.Errorf("Installed = %v, want %v", result.Installed, want)
+	}
+	if lines := fake.Lines(); len(lines) != 0 {
+		t.Errorf("shell install ran commands: %v", lines)
+	}
+	s, _ := state.Load()
+	if !s.IsTracked("shell/prompt") {
+		t.Error("shell/prompt not tracked after install")
+	}
+
+	want := map[string][]appInfo{"shell": {{name: "prompt", desc: "Prompt setup"}}}
+	if got := installedByCategory(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("installedByCategory = %+v, want %+v", got, want)
+	}
+
+	result, err = Remove(cfg, cfg.Apps, false, false)
+	if err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if want := []string{"shell/prompt"}; !reflect.DeepEqual(result.Removed, want) {
+		t.Errorf("Removed = %v, want %v", result.Removed, want)
+	}
+	s, _ = state.Load()
+	if s.IsTracked("shell/prompt") {
+		t.Error("shell/prompt still tracked after remove")
+	}
+}
+
+func TestRemoveRefusesDependents(t *testing.T) {
+	fake := setup(t)
+	fake.On("npm ls -g", npmListing("aws-cdk", "node-tools"), nil)
+
+	cdk := testApp("dev", "aws-cdk", "npm")
+	cdk.Depends = []string{"cli/node-tools"}
+	cfg := &config.Config{Apps: map[string]config.App{
+		"dev/aws-cdk":    cdk,
+		"cli/node-tools": testApp("cli", "node-tools", "npm"),
+	}}
+	target := map[string]config.App{"cli/node-tools": cfg.Apps["cli/node-tools"]}
+
+	_, err := Remove(cfg, target, false, false)
+	if err == nil || !strings.Contains(err.Error(), "cli/node-tools is needed by dev/aws-cdk") {
+		t.Fatalf("Remove error = %v, want dependent reported", err)
+	}
+	if fake.Ran("npm uninstall") {
+		t.Error("uninstalled despite dependents")
+	}
+
+	if _, err := Remove(cfg, target, true, false); err != nil {
+		t.Fatalf("Remove with force: %v", err)
+	}
+	if !fake.Ran("npm uninstall -g node-tools") {
+		t.Errorf("force didn't uninstall: %v", fake.Lines())
+	}
+}
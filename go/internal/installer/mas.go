@@ -0,0 +1,123 @@
+package installer
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/runner"
+)
+
+// masBackend installs Mac App Store apps by ID. mas may prompt for an Apple
+// ID, so commands run interactively.
+type masBackend struct {
+	versions map[int]string // cached app ID -> version, nil when stale
+}
+
+func (b *masBackend) listing() map[int]string {
+	if b.versions == nil {
+		b.versions = masVersions()
+	}
+	return b.versions
+}
+
+// Installed matches apps against mas list by App Store ID
+func (b *masBackend) Installed(apps map[string]config.App) map[string]bool {
+	listing := b.listing()
+	installed := make(map[string]bool)
+	for id, app := range apps {
+		if _, ok := listing[app.ID]; ok {
+			installed[id] = true
+		}
+	}
+	return installed
+}
+
+func (b *masBackend) Install(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+	defer func() { b.versions = nil }()
+	for _, id := range sortedKeys(apps) {
+		LogProgress(fmt.Sprintf("Installing %s from App Store...", id))
+		if err := runCmd(verbose, b.command("install", apps[id])); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
+
+func (b *masBackend) Upgrade(apps map[string]config.App, verbose bool) error {
+	LogProgress(fmt.Sprintf("Upgrading %d App Store apps...", len(apps)))
+	defer func() { b.versions = nil }()
+	return runCmd(verbose, b.upgradeCmd(apps))
+}
+
+func (b *masBackend) Uninstall(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+	defer func() { b.versions = nil }()
+	for _, id := range sortedKeys(apps) {
+		LogProgress(fmt.Sprintf("Removing %s...", id))
+		if err := runCmd(verbose, b.command("uninstall", apps[id])); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
+
+func (b *masBackend) Commands(op Op, apps map[string]config.App) []runner.Cmd {
+	if op == OpUpgrade {
+		return []runner.Cmd{b.upgradeCmd(apps)}
+	}
+	verb := map[Op]string{OpInstall: "install", OpUninstall: "uninstall"}[op]
+	var cmds []runner.Cmd
+	for _, id := range sortedKeys(apps) {
+		cmds = append(cmds, b.command(verb, apps[id]))
+	}
+	return cmds
+}
+
+func (b *masBackend) command(verb string, app config.App) runner.Cmd {
+	cmd := runner.Command("mas", verb, strconv.Itoa(app.ID))
+	cmd.Interactive = true
+	return cmd
+}
+
+func (b *masBackend) upgradeCmd(apps map[string]config.App) runner.Cmd {
+	args := []string{"upgrade"}
+	for _, id := range sortedKeys(apps) {
+		args = append(args, strconv.Itoa(apps[id].ID))
+	}
+	cmd := runner.Command("mas", args...)
+	cmd.Interactive = true
+	return cmd
+}
+
+func (b *masBackend) Version(app config.App) string {
+	return b.listing()[app.ID]
+}
+
+// masVersions returns installed App Store apps by ID with their versions
+func masVersions() map[int]string {
+	versions := make(map[int]string)
+	out, err := run.Output(runner.Command("mas", "list"))
+	if err != nil {
+		return versions
+	}
+	// Lines look like "497799835  Xcode  (15.0)"
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		id, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		version := ""
+		if last := fields[len(fields)-1]; len(fields) > 1 && strings.HasPrefix(last, "(") {
+			version = strings.Trim(last, "()")
+		}
+		versions[id] = version
+	}
+	return versions
+}
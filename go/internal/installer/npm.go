@@ -0,0 +1,107 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/runner"
+)
+
+// npmBackend installs global npm packages one at a time
+type npmBackend struct {
+	versions map[string]string // cached package -> version, nil when stale
+}
+
+func (b *npmBackend) listing() map[string]string {
+	if b.versions == nil {
+		b.versions = npmVersions()
+	}
+	return b.versions
+}
+
+func (b *npmBackend) Installed(apps map[string]config.App) map[string]bool {
+	listing := b.listing()
+	installed := make(map[string]bool)
+	for id, app := range apps {
+		if _, ok := listing[app.PackageName()]; ok {
+			installed[id] = true
+		}
+	}
+	return installed
+}
+
+func (b *npmBackend) Install(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+	defer func() { b.versions = nil }()
+	for _, id := range sortedKeys(apps) {
+		LogProgress(fmt.Sprintf("Installing %s...", id))
+		if err := runCmd(verbose, b.command(OpInstall, apps[id])); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
+
+func (b *npmBackend) Upgrade(apps map[string]config.App, verbose bool) error {
+	LogProgress(fmt.Sprintf("Upgrading %d npm packages...", len(apps)))
+	defer func() { b.versions = nil }()
+	var firstErr error
+	for _, id := range sortedKeys(apps) {
+		if err := runCmd(verbose, b.command(OpUpgrade, apps[id])); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (b *npmBackend) Uninstall(apps map[string]config.App, verbose bool) map[string]error {
+	failed := make(map[string]error)
+	defer func() { b.versions = nil }()
+	for _, id := range sortedKeys(apps) {
+		LogProgress(fmt.Sprintf("Removing %s...", id))
+		if err := runCmd(verbose, b.command(OpUninstall, apps[id])); err != nil {
+			failed[id] = err
+		}
+	}
+	return failed
+}
+
+func (b *npmBackend) Commands(op Op, apps map[string]config.App) []runner.Cmd {
+	var cmds []runner.Cmd
+	for _, id := range sortedKeys(apps) {
+		cmds = append(cmds, b.command(op, apps[id]))
+	}
+	return cmds
+}
+
+func (b *npmBackend) command(op Op, app config.App) runner.Cmd {
+	verb := map[Op]string{OpInstall: "install", OpUpgrade: "update", OpUninstall: "uninstall"}[op]
+	return runner.Command("npm", verb, "-g", app.PackageName())
+}
+
+func (b *npmBackend) Version(app config.App) string {
+	return b.listing()[app.PackageName()]
+}
+
+// npmVersions returns global npm packages with their versions, read from
+// a single npm ls
+func npmVersions() map[string]string {
+	versions := make(map[string]string)
+	// npm ls exits non-zero on problems like missing peers but still
+	// prints the tree, so parse whatever came back
+	out, _ := run.Output(runner.Command("npm", "ls", "-g", "--depth=0", "--json"))
+
+	var tree struct {
+		Dependencies map[string]struct {
+			Version string `json:"version"`
+		} `json:"dependencies"`
+	}
+	if err := json.Unmarshal(out, &tree); err != nil {
+		return versions
+	}
+	for name, dep := range tree.Dependencies {
+		versions[name] = dep.Version
+	}
+	return versions
+}
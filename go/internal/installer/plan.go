@@ -0,0 +1,377 @@
+package installer
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// Plan describes what a mutating command would do, without doing it
+type Plan struct {
+	Action      string       `json:"action"`
+	Unchanged   []string     `json:"unchanged"` // already in the desired state
+	Unsupported []string     `json:"unsupported,omitempty"`
+	Steps       []PlanStep   `json:"steps"`
+	Hooks       []PlanHook   `json:"hooks,omitempty"`
+	Configs     []PlanConfig `json:"configs,omitempty"`
+	Forgotten   []string     `json:"forgotten,omitempty"` // tracked but already gone
+	Cleanup     []PlanHook   `json:"cleanup,omitempty"`   // undoing config on remove
+}
+
+// PlanConfig is a config file that would be deployed
+type PlanConfig struct {
+	App    string `json:"app"`
+	Source string `json:"source"`
+	Dest   string `json:"dest"`
+	Mode   string `json:"mode"`
+}
+
+// addCompletion appends app's completion generator as a hook
+func (p *Plan) addCompletion(id string, app config.App) {
+	if app.Completions != "" {
+		cmd := fmt.Sprintf("%s > %s", app.Completions, paths.Tilde(completionFile(app)))
+		p.Hooks = append(p.Hooks, PlanHook{App: id, Command: cmd})
+	}
+}
+
+// addGitConfig appends the git settings in keys as hooks
+func (p *Plan) addGitConfig(id string, app config.App, keys []string) {
+	for _, key := range keys {
+		p.Hooks = append(p.Hooks, PlanHook{App: id, Command: gitSetCmd(key, app.GitConfig[key]).String()})
+	}
+}
+
+// addConfig appends app's config file if it would be deployed
+func (p *Plan) addConfig(id string, app config.App, rec state.Dotfile) {
+	switch checkDotfile(app, rec) {
+	case dotfilePending, dotfileOutdated:
+		d := dotfileFor(app)
+		p.Configs = append(p.Configs, PlanConfig{App: id, Source: d.Source, Dest: d.Dest, Mode: d.Mode})
+	}
+}
+
+// addCleanup appends what removing app id undoes: its config file, git
+// settings and completion. Files and settings changed locally are left
+// alone, as Remove does.
+func (p *Plan) addCleanup(s *state.State, id string, app config.App) {
+	add := func(cmd string) {
+		p.Cleanup = append(p.Cleanup, PlanHook{App: id, Command: cmd})
+	}
+	if rec, ok := s.Dotfiles[id]; ok && ownsDotfile(rec) {
+		add("rm " + paths.Tilde(rec.Dest))
+		if rec.Backup != "" {
+			add(fmt.Sprintf("mv %s %s", paths.Tilde(rec.Backup), paths.Tilde(rec.Dest)))
+		}
+	}
+	cmds, _ := gitReverts(s, id)
+	for _, cmd := range cmds {
+		add(cmd.String())
+	}
+	if app.Completions != "" {
+		if _, err := os.Stat(completionFile(app)); err == nil {
+			add("rm " + paths.Tilde(completionFile(app)))
+		}
+	}
+}
+
+// PlanStep is one backend batch, in execution order
+type PlanStep struct {
+	Apps     []string `json:"apps"`
+	Commands []string `json:"commands"`
+	Brewfile string   `json:"brewfile,omitempty"`
+}
+
+// PlanHook is a post_install command that would run, unless its guards say
+// it's already done
+type PlanHook struct {
+	App     string `json:"app"`
+	Command string `json:"command"`
+	Creates string `json:"creates,omitempty"`
+	Unless  string `json:"unless,omitempty"`
+}
+
+func newPlanHook(id string, hook config.Hook) PlanHook {
+	return PlanHook{App: id, Command: hook.Run, Creates: hook.Creates, Unless: hook.Unless}
+}
+
+func newPlan(action string) *Plan {
+	return &Plan{Action: action, Unchanged: []string{}, Steps: []PlanStep{}}
+}
+
+// addStep appends a step for apps handled by b
+func (p *Plan) addStep(b Backend, op Op, apps map[string]config.App) {
+	step := PlanStep{Apps: sortedKeys(apps), Commands: []string{}}
+	for _, cmd := range b.Commands(op, apps) {
+		step.Commands = append(step.Commands, cmd.String())
+	}
+	if op == OpInstall {
+		step.Brewfile = BrewfileContent(apps)
+	}
+	p.Steps = append(p.Steps, step)
+}
+
+// PlanInstall returns what Install would do for apps
+func PlanInstall(apps map[string]config.App) (*Plan, error) {
+	plan := newPlan("install")
+
+	levels, err := config.InstallOrder(apps)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, unsupported := groupByBackend(apps)
+	installed := make(map[string]bool)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			installed[id] = true
+		}
+	}
+	plan.Unsupported = unsupported
+
+	for _, level := range levels {
+		pending := make(map[string]config.App)
+		for _, id := range level {
+			if installed[id] {
+				plan.Unchanged = append(plan.Unchanged, id)
+				continue
+			}
+			pending[id] = apps[id]
+		}
+
+		levelGroups, _ := groupByBackend(pending)
+		for _, g := range levelGroups {
+			plan.addStep(g.backend, OpInstall, g.apps)
+			for _, id := range sortedKeys(g.apps) {
+				plan.addConfig(id, g.apps[id], state.Dotfile{})
+				plan.addGitConfig(id, g.apps[id], gitConfigKeys(g.apps[id]))
+				plan.addCompletion(id, g.apps[id])
+				for _, hook := range g.apps[id].PostInstall {
+					plan.Hooks = append(plan.Hooks, newPlanHook(id, hook))
+				}
+			}
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanUpgrade returns what Upgrade would do
+func PlanUpgrade(cfg *config.Config) (*Plan, error) {
+	plan := newPlan("update")
+
+	// Read state without saving the bare-name migration
+	s, err := state.Load()
+	if err != nil {
+		return nil, err
+	}
+	migrateState(cfg, s)
+
+	tracked := make(map[string]config.App)
+	for id := range s.Installed {
+		if app, ok := cfg.Apps[id]; ok {
+			tracked[id] = app
+		}
+	}
+
+	groups, _ := groupByBackend(tracked)
+	for _, g := range groups {
+		if len(g.backend.Commands(OpUpgrade, g.apps)) == 0 {
+			plan.Unchanged = append(plan.Unchanged, sortedKeys(g.apps)...)
+			continue
+		}
+		plan.addStep(g.backend, OpUpgrade, g.apps)
+		for _, id := range sortedKeys(g.apps) {
+			plan.addCompletion(id, g.apps[id])
+		}
+	}
+
+	// Update configures every installed app afterwards
+	configure, err := PlanConfigure(cfg, cfg.Apps)
+	if err != nil {
+		return nil, err
+	}
+	for _, hook := range configure.Hooks {
+		if !slices.Contains(plan.Hooks, hook) {
+			plan.Hooks = append(plan.Hooks, hook)
+		}
+	}
+	plan.Configs = configure.Configs
+
+	return plan, nil
+}
+
+// PlanConfigure returns what Configure would do for apps
+func PlanConfigure(cfg *config.Config, apps map[string]config.App) (*Plan, error) {
+	plan := newPlan("configure")
+
+	// Read state without saving the bare-name migration
+	s, err := state.Load()
+	if err != nil {
+		return nil, err
+	}
+	migrateState(cfg, s)
+
+	installed := make(map[string]bool)
+	groups, _ := groupByBackend(apps)
+	for _, g := range groups {
+		for id := range g.backend.Installed(g.apps) {
+			installed[id] = true
+		}
+	}
+
+	for _, id := range sortedKeys(apps) {
+		if !installed[id] || !needsConfigure(s, id, apps[id]) {
+			plan.Unchanged = append(plan.Unchanged, id)
+			continue
+		}
+		plan.addConfig(id, apps[id], s.Dotfiles[id])
+		pending, _ := pendingGitConfig(s, id, apps[id])
+		plan.addGitConfig(id, apps[id], pending)
+		if !hasCompletion(apps[id]) {
+			plan.addCompletion(id, apps[id])
+		}
+		for _, hook := range pendingHooks(s, id, apps[id]) {
+			plan.Hooks = append(plan.Hooks, newPlanHook(id, hook))
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanRemove returns what Remove would do for apps
+func PlanRemove(cfg *config.Config, apps map[string]config.App, force bool) (*Plan, error) {
+	plan := newPlan("remove")
+
+	// Read state without saving the bare-name migration
+	s, err := state.Load()
+	if err != nil {
+		return nil, err
+	}
+	migrateState(cfg, s)
+
+	if !force {
+		if err := checkDependents(cfg, s, apps); err != nil {
+			return nil, err
+		}
+	}
+
+	groups, unsupported := groupByBackend(apps)
+	plan.Unsupported = unsupported
+
+	for _, g := range groups {
+		installed := g.backend.Installed(g.apps)
+		present := make(map[string]config.App)
+		for _, id := range sortedKeys(g.apps) {
+			switch {
+			case installed[id]:
+				present[id] = g.apps[id]
+			case s.IsTracked(id):
+				// Gone already, Remove just forgets it
+				plan.Forgotten = append(plan.Forgotten, id)
+			default:
+				plan.Unchanged = append(plan.Unchanged, id)
+				continue
+			}
+			plan.addCleanup(s, id, g.apps[id])
+		}
+		if len(present) > 0 {
+			plan.addStep(g.backend, OpUninstall, present)
+		}
+	}
+
+	return plan, nil
+}
+
+// PlanHooks returns what RunHooks would do for apps
+func PlanHooks(apps map[string]config.App) *Plan {
+	plan := newPlan("hooks")
+	for _, id := range sortedKeys(apps) {
+		if len(apps[id].PostInstall) == 0 {
+			plan.Unchanged = append(plan.Unchanged, id)
+		}
+		for _, hook := range apps[id].PostInstall {
+			plan.Hooks = append(plan.Hooks, newPlanHook(id, hook))
+		}
+	}
+	return plan
+}
+
+// PrintPlan writes a plan for humans
+func PrintPlan(plan *Plan) {
+	LogProgress(fmt.Sprintf("Dry run: %s", plan.Action))
+
+	if len(plan.Unchanged) > 0 {
+		LogDim(fmt.Sprintf("Nothing to do for: %s", strings.Join(plan.Unchanged, ", ")))
+	}
+	if len(plan.Unsupported) > 0 {
+		LogWarn(fmt.Sprintf("Unsupported install type: %s", strings.Join(plan.Unsupported, ", ")))
+	}
+
+	for i, step := range plan.Steps {
+		fmt.Println()
+		fmt.Println(progressStyle.Render(fmt.Sprintf("Step %d: %s", i+1, strings.Join(step.Apps, ", "))))
+		if step.Brewfile != "" {
+			LogDim("Brewfile:")
+			for _, line := range strings.Split(strings.TrimSpace(step.Brewfile), "\n") {
+				LogDim("  " + line)
+			}
+		}
+		for _, cmd := range step.Commands {
+			LogDim("$ " + cmd)
+		}
+	}
+
+	if len(plan.Hooks) > 0 {
+		fmt.Println()
+		fmt.Println(progressStyle.Render("post_install hooks"))
+		for _, hook := range plan.Hooks {
+			line := fmt.Sprintf("%s: %s", hook.App, hook.Command)
+			if hook.Creates != "" {
+				line += fmt.Sprintf(" (unless %s exists)", hook.Creates)
+			}
+			if hook.Unless != "" {
+				line += fmt.Sprintf(" (unless %s)", hook.Unless)
+			}
+			LogDim(line)
+		}
+	}
+
+	if len(plan.Configs) > 0 {
+		fmt.Println()
+		fmt.Println(progressStyle.Render("config files"))
+		for _, c := range plan.Configs {
+			LogDim(fmt.Sprintf("%s: %s %s -> %s", c.App, c.Mode, paths.Tilde(c.Source), paths.Tilde(c.Dest)))
+		}
+	}
+
+	if len(plan.Forgotten) > 0 {
+		fmt.Println()
+		LogDim(fmt.Sprintf("Already gone, forgotten: %s", strings.Join(plan.Forgotten, ", ")))
+	}
+	if len(plan.Cleanup) > 0 {
+		fmt.Println()
+		fmt.Println(progressStyle.Render("cleanup"))
+		for _, c := range plan.Cleanup {
+			LogDim(fmt.Sprintf("%s: %s", c.App, c.Command))
+		}
+	}
+
+	if len(plan.Steps) == 0 && len(plan.Hooks) == 0 && len(plan.Configs) == 0 && len(plan.Forgotten) == 0 && len(plan.Cleanup) == 0 {
+		LogSuccess("Nothing to do")
+	}
+}
+
+// WriteJSON writes v as indented JSON
+func WriteJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(v)
+}
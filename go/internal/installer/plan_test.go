@@ -0,0 +1,133 @@
+package installer
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+func TestPlanInstallRunsNothing(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "jq 1.7.1\n", nil)
+
+	gh := testApp("git", "gh", "brew")
+	gh.PostInstall = []config.Hook{{Run: "gh extension install dlvhdr/gh-dash"}}
+	apps := map[string]config.App{
+		"cli/jq":  testApp("cli", "jq", "brew"),
+		"git/gh":  gh,
+		"cli/cdk": testApp("cli", "cdk", "npm"),
+	}
+	plan, err := PlanInstall(apps)
+	if err != nil {
+		t.Fatalf("PlanInstall: %v", err)
+	}
+
+	want := &Plan{
+		Action:    "install",
+		Unchanged: []string{"cli/jq"},
+		Steps: []PlanStep{
+			{
+				Apps:     []string{"git/gh"},
+				Commands: []string{"brew bundle --file=" + paths.Brewfile()},
+				Brewfile: "brew \"gh\"\n",
+			},
+			{
+				Apps:     []string{"cli/cdk"},
+				Commands: []string{"npm install -g cdk"},
+			},
+		},
+		Hooks: []PlanHook{{App: "git/gh", Command: "gh extension install dlvhdr/gh-dash"}},
+	}
+	if !reflect.DeepEqual(plan, want) {
+		t.Errorf("plan = %+v\nwant %+v", plan, want)
+	}
+
+	for _, line := range fake.Lines() {
+		if !strings.HasPrefix(line, "brew list") && !strings.HasPrefix(line, "npm ls") {
+			t.Errorf("dry run ran %q", line)
+		}
+	}
+	if _, err := os.Stat(paths.Brewfile()); err == nil {
+		t.Error("dry run wrote a Brewfile")
+	}
+}
+
+func TestPlanRemoveLeavesState(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "jq 1.7.1\n", nil)
+
+	s, _ := state.Load()
+	s.MarkInstalled("cli/jq")
+
+	cfg := &config.Config{Apps: map[string]config.App{"cli/jq": testApp("cli", "jq", "brew")}}
+	plan, err := PlanRemove(cfg, cfg.Apps, false)
+	if err != nil {
+		t.Fatalf("PlanRemove: %v", err)
+	}
+
+	if len(plan.Steps) != 1 || plan.Steps[0].Commands[0] != "brew uninstall jq" {
+		t.Errorf("steps = %+v, want brew uninstall jq", plan.Steps)
+	}
+	if fake.Ran("brew uninstall") {
+		t.Error("dry run uninstalled")
+	}
+	if s, _ := state.Load(); !s.IsTracked("cli/jq") {
+		t.Error("dry run untracked cli/jq")
+	}
+}
+
+func TestPlanRemoveCleanup(t *testing.T) {
+	fake := setup(t)
+	fake.On("brew list --formula", "git-delta 0.18.2\ntmux 3.5\n", nil)
+	fakeGitConfig(fake, map[string]string{"core.pager": "delta"})
+
+	tmux := writeConfigApp(t, "")
+	dest := filepath.Join(paths.Home(), ".tmux.conf")
+	os.WriteFile(dest, []byte("mine\n"), 0644)
+	if err := deployDotfile("cli/tmux", tmux); err != nil {
+		t.Fatalf("deployDotfile: %v", err)
+	}
+
+	s, _ := state.Load()
+	s.MarkInstalled("cli/tmux")
+	s.MarkInstalled("git/git-delta")
+	s.MarkInstalled("git/gh")
+	s.RecordGitSetting("git/git-delta", "core.pager", state.GitSetting{Value: "delta", Previous: "less"})
+
+	gh := testApp("git", "gh", "brew")
+	gh.Completions = "gh completion -s zsh"
+	os.MkdirAll(paths.Completions(), 0755)
+	os.WriteFile(completionFile(gh), []byte("#compdef gh\n"), 0644)
+
+	cfg := &config.Config{Apps: map[string]config.App{
+		"git/git-delta": testApp("git", "git-delta", "brew"),
+		"git/gh":        gh,
+		"cli/tmux":      tmux,
+	}}
+	plan, err := PlanRemove(cfg, cfg.Apps, false)
+	if err != nil {
+		t.Fatalf("PlanRemove: %v", err)
+	}
+
+	if want := []string{"git/gh"}; !reflect.DeepEqual(plan.Forgotten, want) {
+		t.Errorf("forgotten = %v, want %v", plan.Forgotten, want)
+	}
+	want := []PlanHook{
+		{App: "cli/tmux", Command: "rm ~/.tmux.conf"},
+		{App: "cli/tmux", Command: "mv ~/.tmux.conf.boots-backup ~/.tmux.conf"},
+		{App: "git/gh", Command: "rm " + paths.Tilde(completionFile(gh))},
+		{App: "git/git-delta", Command: "git config --global core.pager less"},
+	}
+	if !reflect.DeepEqual(plan.Cleanup, want) {
+		t.Errorf("cleanup = %+v, want %+v", plan.Cleanup, want)
+	}
+	if fake.Ran("git config --global core.pager less") {
+		t.Error("dry run reverted git config")
+	}
+}
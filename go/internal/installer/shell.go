@@ -0,0 +1,48 @@
+package installer
+
+import (
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/runner"
+	"github.com/schmoli/macos-setup/internal/state"
+)
+
+// shellBackend handles shell-only packages that have no binary. They are
+// "installed" by tracking them in state, which makes EnsureShellIntegration
+// pick up their init files, and removed by untracking them.
+type shellBackend struct{}
+
+func (b *shellBackend) Installed(apps map[string]config.App) map[string]bool {
+	installed := make(map[string]bool)
+	s, err := state.Load()
+	if err != nil {
+		return installed
+	}
+	for id := range apps {
+		if s.IsTracked(id) {
+			installed[id] = true
+		}
+	}
+	return installed
+}
+
+// Install has nothing to fetch; tracking happens after it succeeds
+func (b *shellBackend) Install(apps map[string]config.App, verbose bool) map[string]error {
+	return make(map[string]error)
+}
+
+func (b *shellBackend) Upgrade(apps map[string]config.App, verbose bool) error {
+	return nil
+}
+
+// Uninstall has nothing to delete; untracking the app drops its init files
+func (b *shellBackend) Uninstall(apps map[string]config.App, verbose bool) map[string]error {
+	return make(map[string]error)
+}
+
+func (b *shellBackend) Commands(op Op, apps map[string]config.App) []runner.Cmd {
+	return nil
+}
+
+func (b *shellBackend) Version(app config.App) string {
+	return ""
+}
@@ -0,0 +1,199 @@
+package installer
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/schmoli/macos-setup/internal/config"
+	"github.com/schmoli/macos-setup/internal/paths"
+)
+
+// shellSyntax renders the declarative env, path and aliases entries of
+// app.yaml, and boots' own setup, for one shell
+type shellSyntax struct {
+	export         func(name, value string) string
+	path           func(dirs []string) string
+	alias          func(name, value string) string
+	sourceIfExists func(file string) string // line added to the rc file
+	header         func() string            // boots' PATH, mise and completions
+}
+
+// shellSyntaxes maps the shells in config.Shells to their syntax
+var shellSyntaxes = map[string]shellSyntax{
+	"zsh": {
+		export:         posixExport,
+		path:           posixPath,
+		alias:          posixAlias,
+		sourceIfExists: posixSourceIfExists,
+		header:         zshHeader,
+	},
+	"bash": {
+		export:         posixExport,
+		path:           posixPath,
+		alias:          posixAlias,
+		sourceIfExists: posixSourceIfExists,
+		header:         bashHeader,
+	},
+	"fish": {
+		export: func(name, value string) string {
+			return fmt.Sprintf("set -gx %s %s", name, fishDoubleQuote(value))
+		},
+		path: func(dirs []string) string {
+			quoted := make([]string, len(dirs))
+			for i, dir := range dirs {
+				quoted[i] = fishDoubleQuote(homeVar(dir))
+			}
+			return fmt.Sprintf("set -gx PATH %s $PATH", strings.Join(quoted, " "))
+		},
+		alias: func(name, value string) string {
+			return fmt.Sprintf("alias %s %s", name, fishSingleQuote(value))
+		},
+		sourceIfExists: func(file string) string {
+			return fmt.Sprintf("test -f %s; and source %s", file, file)
+		},
+		header: fishHeader,
+	},
+}
+
+func posixExport(name, value string) string {
+	return fmt.Sprintf("export %s=%s", name, doubleQuote(value))
+}
+
+func posixPath(dirs []string) string {
+	quoted := make([]string, len(dirs))
+	for i, dir := range dirs {
+		quoted[i] = escapeDouble(homeVar(dir))
+	}
+	return fmt.Sprintf(`export PATH="%s:$PATH"`, strings.Join(quoted, ":"))
+}
+
+func posixAlias(name, value string) string {
+	return fmt.Sprintf("alias %s=%s", name, singleQuote(value))
+}
+
+func posixSourceIfExists(file string) string {
+	return fmt.Sprintf("[[ -f %s ]] && source %s", file, file)
+}
+
+// bootsDir returns a boots directory for use inside double quotes in an
+// init file
+func bootsDir(dir string) string {
+	return homeVar(paths.Tilde(dir))
+}
+
+func zshHeader() string {
+	return `# boots shell integration (auto-generated)
+
+# Add boots to PATH
+export PATH="` + bootsDir(paths.Bin()) + `:$PATH"
+
+# mise initialization
+eval "$(mise activate zsh)"
+
+# Generated completions, ahead of compinit
+fpath=(` + bootsDir(paths.Completions()) + ` $fpath)
+
+# Ensure compinit is loaded for completions
+autoload -Uz compinit && compinit -C
+
+# Complete boots commands and app names
+_boots() {
+  if (( CURRENT == 2 )); then
+    compadd -- ${(f)"$(boots __complete commands 2>/dev/null)"}
+  elif [[ ${words[2]} == (install|remove|configure|hooks) ]]; then
+    compadd -- ${(f)"$(boots __complete apps 2>/dev/null)"}
+  fi
+}
+compdef _boots boots
+`
+}
+
+func bashHeader() string {
+	return `# boots shell integration (auto-generated)
+
+# Add boots to PATH
+export PATH="` + bootsDir(paths.Bin()) + `:$PATH"
+
+# mise initialization
+eval "$(mise activate bash)"
+
+# Complete boots commands and app names
+_boots() {
+  local cur=${COMP_WORDS[COMP_CWORD]}
+  if (( COMP_CWORD == 1 )); then
+    COMPREPLY=($(compgen -W "$(boots __complete commands 2>/dev/null)" -- "$cur"))
+    return
+  fi
+  case ${COMP_WORDS[1]} in
+    install|remove|configure|hooks)
+      COMPREPLY=($(compgen -W "$(boots __complete apps 2>/dev/null)" -- "$cur")) ;;
+  esac
+}
+complete -F _boots boots
+`
+}
+
+func fishHeader() string {
+	return `# boots shell integration (auto-generated)
+
+# Add boots to PATH
+set -gx PATH "` + bootsDir(paths.Bin()) + `" $PATH
+
+# mise initialization
+mise activate fish | source
+
+# Complete boots commands and app names
+complete -c boots -f -n __fish_use_subcommand -a "(boots __complete commands 2>/dev/null)"
+complete -c boots -f -n "__fish_seen_subcommand_from install remove configure hooks" -a "(boots __complete apps 2>/dev/null)"
+`
+}
+
+// renderShellInit renders si in shell's syntax, one line per entry
+func renderShellInit(si config.ShellInit, shell string) []string {
+	syntax := shellSyntaxes[shell]
+	var lines []string
+	for _, e := range si.Env {
+		lines = append(lines, syntax.export(e.Name, e.Value))
+	}
+	if len(si.Path) > 0 {
+		lines = append(lines, syntax.path(si.Path))
+	}
+	for _, e := range si.Aliases {
+		lines = append(lines, syntax.alias(e.Name, e.Value))
+	}
+	return lines
+}
+
+// homeVar replaces a leading ~ with $HOME, which expands inside quotes
+func homeVar(dir string) string {
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		return "$HOME" + dir[1:]
+	}
+	return dir
+}
+
+// doubleQuote quotes s so that $VARS still expand
+func doubleQuote(s string) string {
+	return `"` + escapeDouble(s) + `"`
+}
+
+// escapeDouble escapes the characters that are special inside double
+// quotes, except $
+func escapeDouble(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "`", "\\`").Replace(s)
+}
+
+// singleQuote quotes s literally
+func singleQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishDoubleQuote quotes s for fish so that $VARS still expand
+func fishDoubleQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// fishSingleQuote quotes s literally for fish, which escapes ' with \
+func fishSingleQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,117 @@
+package paths
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// root, when set, stands in for the home directory so boots never touches
+// real files (--root)
+var root string
+
+// SetRoot redirects every path boots reads or writes under dir
+func SetRoot(dir string) {
+	root = dir
+}
+
+// Sandboxed reports whether a root is set
+func Sandboxed() bool {
+	return root != ""
+}
+
+// Home returns the user's home directory, or the sandbox root
+func Home() string {
+	if root != "" {
+		return root
+	}
+	home, _ := os.UserHomeDir()
+	return home
+}
+
+// Boots returns the boots home: $BOOTS_HOME if set, else ~/.config/boots.
+// Under a sandbox root, $BOOTS_HOME is taken relative to the root.
+func Boots() string {
+	if env := os.Getenv("BOOTS_HOME"); env != "" {
+		if root != "" {
+			return filepath.Join(root, env)
+		}
+		return env
+	}
+	return filepath.Join(Home(), ".config", "boots")
+}
+
+// Repo returns the checkout of the boots repo
+func Repo() string {
+	return filepath.Join(Boots(), "repo")
+}
+
+// Packages returns the packages directory in the repo
+func Packages() string {
+	return filepath.Join(Repo(), "packages")
+}
+
+// Bin returns the directory holding the boots binary
+func Bin() string {
+	return filepath.Join(Boots(), "bin")
+}
+
+// State returns the state file
+func State() string {
+	return filepath.Join(Boots(), "state.yaml")
+}
+
+// Settings returns the user's settings file
+func Settings() string {
+	return filepath.Join(Boots(), "settings.yaml")
+}
+
+// Completions returns the directory of generated zsh completions, added
+// to fpath by init.zsh
+func Completions() string {
+	return filepath.Join(Boots(), "completions")
+}
+
+// Init returns the generated shell integration file for shell
+func Init(shell string) string {
+	return filepath.Join(Boots(), "init."+shell)
+}
+
+// Brewfile returns the temporary Brewfile used for brew bundle
+func Brewfile() string {
+	return filepath.Join(Boots(), "Brewfile")
+}
+
+// RcFiles returns the startup files of shell that boots adds itself to:
+// ~/.zshrc, ~/.bash_profile and ~/.bashrc (macOS terminals start bash as a
+// login shell, which skips .bashrc), or ~/.config/fish/config.fish
+func RcFiles(shell string) []string {
+	switch shell {
+	case "bash":
+		return []string{filepath.Join(Home(), ".bash_profile"), filepath.Join(Home(), ".bashrc")}
+	case "fish":
+		return []string{filepath.Join(Home(), ".config", "fish", "config.fish")}
+	}
+	return []string{filepath.Join(Home(), "."+shell+"rc")}
+}
+
+// RcMarker returns the marker listing the startup files boots modified
+func RcMarker() string {
+	return filepath.Join(Boots(), ".rc-modified")
+}
+
+// Tilde abbreviates paths in the home directory with ~ for use in shell
+// files. Sandboxed paths stay absolute, since ~ wouldn't point at them.
+func Tilde(path string) string {
+	if root != "" {
+		return path
+	}
+	home := Home()
+	if path == home {
+		return "~"
+	}
+	if rel, ok := strings.CutPrefix(path, home+string(filepath.Separator)); ok {
+		return "~/" + rel
+	}
+	return path
+}
@@ -0,0 +1,46 @@
+package paths
+
+import "testing"
+
+func TestPaths(t *testing.T) {
+	t.Setenv("HOME", "/Users/me")
+
+	tests := []struct {
+		name      string
+		root      string
+		bootsHome string
+		state     string
+		zshrc     string
+		tilde     string
+	}{
+		{"default", "", "", "/Users/me/.config/boots/state.yaml", "/Users/me/.zshrc", "~/.config/boots/init.zsh"},
+		{"boots home", "", "/opt/boots", "/opt/boots/state.yaml", "/Users/me/.zshrc", "/opt/boots/init.zsh"},
+		{"root", "/tmp/sandbox", "", "/tmp/sandbox/.config/boots/state.yaml", "/tmp/sandbox/.zshrc", "/tmp/sandbox/.config/boots/init.zsh"},
+		{"root and boots home", "/tmp/sandbox", "/opt/boots", "/tmp/sandbox/opt/boots/state.yaml", "/tmp/sandbox/.zshrc", "/tmp/sandbox/opt/boots/init.zsh"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BOOTS_HOME", tt.bootsHome)
+			SetRoot(tt.root)
+			defer SetRoot("")
+
+			if got := State(); got != tt.state {
+				t.Errorf("State() = %q, want %q", got, tt.state)
+			}
+			if got := RcFiles("zsh"); len(got) != 1 || got[0] != tt.zshrc {
+				t.Errorf("RcFiles(zsh) = %q, want [%q]", got, tt.zshrc)
+			}
+			if got := Tilde(Init("zsh")); got != tt.tilde {
+				t.Errorf("Tilde(Init(zsh)) = %q, want %q", got, tt.tilde)
+			}
+		})
+	}
+
+	if got := RcFiles("fish"); len(got) != 1 || got[0] != "/Users/me/.config/fish/config.fish" {
+		t.Errorf("RcFiles(fish) = %q, want ~/.config/fish/config.fish", got)
+	}
+	if got := RcFiles("bash"); len(got) != 2 || got[0] != "/Users/me/.bash_profile" || got[1] != "/Users/me/.bashrc" {
+		t.Errorf("RcFiles(bash) = %q, want ~/.bash_profile and ~/.bashrc", got)
+	}
+}
@@ -0,0 +1,80 @@
+package runner
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fake records commands instead of running them and replays scripted
+// responses. Commands with no matching response succeed with no output.
+type Fake struct {
+	Calls []Cmd
+	rules []fakeRule
+}
+
+type fakeRule struct {
+	prefix  string
+	respond func(Cmd) (string, error)
+}
+
+// FakeExitError is returned for scripted command failures
+type FakeExitError struct {
+	Code int
+}
+
+func (e *FakeExitError) Error() string {
+	return fmt.Sprintf("exit status %d", e.Code)
+}
+
+// Exit returns an error that looks like a command exiting with code
+func Exit(code int) error {
+	return &FakeExitError{Code: code}
+}
+
+// On scripts the output and error for commands whose line starts with
+// prefix. Later rules take precedence, so tests can change a response
+// partway through.
+func (f *Fake) On(prefix, out string, err error) {
+	f.OnFunc(prefix, func(Cmd) (string, error) { return out, err })
+}
+
+// OnFunc scripts a response computed from the command
+func (f *Fake) OnFunc(prefix string, respond func(Cmd) (string, error)) {
+	f.rules = append(f.rules, fakeRule{prefix: prefix, respond: respond})
+}
+
+func (f *Fake) Run(c Cmd) error {
+	_, err := f.Output(c)
+	return err
+}
+
+func (f *Fake) Output(c Cmd) ([]byte, error) {
+	f.Calls = append(f.Calls, c)
+	line := c.String()
+	for i := len(f.rules) - 1; i >= 0; i-- {
+		if strings.HasPrefix(line, f.rules[i].prefix) {
+			out, err := f.rules[i].respond(c)
+			return []byte(out), err
+		}
+	}
+	return nil, nil
+}
+
+// Lines returns the command lines run so far
+func (f *Fake) Lines() []string {
+	lines := make([]string, len(f.Calls))
+	for i, c := range f.Calls {
+		lines[i] = c.String()
+	}
+	return lines
+}
+
+// Ran reports whether a command starting with prefix was run
+func (f *Fake) Ran(prefix string) bool {
+	for _, line := range f.Lines() {
+		if strings.HasPrefix(line, prefix) {
+			return true
+		}
+	}
+	return false
+}
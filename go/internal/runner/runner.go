@@ -0,0 +1,76 @@
+package runner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Cmd describes an external command
+type Cmd struct {
+	Name        string
+	Args        []string
+	Dir         string   // working directory, current if empty
+	Env         []string // KEY=value pairs added to boots' environment
+	Interactive bool     // connect stdin, for commands that may prompt
+}
+
+// Command returns a Cmd for name and args
+func Command(name string, args ...string) Cmd {
+	return Cmd{Name: name, Args: args}
+}
+
+// String returns the command line with the program's base name, as shown
+// to users and matched by Fake
+func (c Cmd) String() string {
+	return strings.Join(append([]string{filepath.Base(c.Name)}, c.Args...), " ")
+}
+
+// Runner executes external commands
+type Runner interface {
+	// Run runs cmd with output streamed to the terminal
+	Run(cmd Cmd) error
+	// Output runs cmd and returns its stdout
+	Output(cmd Cmd) ([]byte, error)
+}
+
+// Exec runs commands with os/exec
+type Exec struct{}
+
+func (Exec) Run(c Cmd) error {
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	if c.Interactive {
+		cmd.Stdin = os.Stdin
+	}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+func (Exec) Output(c Cmd) ([]byte, error) {
+	cmd := exec.Command(c.Name, c.Args...)
+	cmd.Dir = c.Dir
+	if len(c.Env) > 0 {
+		cmd.Env = append(os.Environ(), c.Env...)
+	}
+	if c.Interactive {
+		cmd.Stdin = os.Stdin
+	}
+	return cmd.Output()
+}
+
+// ExitCode returns the exit code carried by err, or -1 if there is none
+func ExitCode(err error) int {
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		return exitErr.ExitCode()
+	}
+	if exitErr, ok := err.(*FakeExitError); ok {
+		return exitErr.Code
+	}
+	return -1
+}
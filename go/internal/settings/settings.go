@@ -0,0 +1,63 @@
+package settings
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/schmoli/macos-setup/internal/paths"
+	"gopkg.in/yaml.v3"
+)
+
+// Settings holds per-machine choices from settings.yaml in the boots home
+type Settings struct {
+	Profile string            `yaml:"profile"` // e.g. work or personal, for templates
+	Vars    map[string]string `yaml:"vars"`    // user values for config templates
+	Shell   Shells            `yaml:"shell"`   // shells to set up, zsh if empty
+}
+
+// Shells is the shell setting: a single shell name or a list of them
+type Shells []string
+
+// UnmarshalYAML accepts both a name and a list
+func (s *Shells) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*s = Shells{value.Value}
+		return nil
+	}
+	var list []string
+	if err := value.Decode(&list); err != nil {
+		return err
+	}
+	*s = list
+	return nil
+}
+
+// Shells returns the shells boots sets up integration for
+func (s *Settings) Shells() []string {
+	if len(s.Shell) == 0 {
+		return []string{"zsh"}
+	}
+	return s.Shell
+}
+
+// Load reads the settings file. A missing file gives empty settings; a
+// broken one is an error, since templates would render wrong values.
+func Load() (*Settings, error) {
+	s := &Settings{Vars: make(map[string]string)}
+
+	data, err := os.ReadFile(paths.Settings())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return s, nil
+		}
+		return s, err
+	}
+
+	if err := yaml.Unmarshal(data, s); err != nil {
+		return s, fmt.Errorf("%s: %w", paths.Tilde(paths.Settings()), err)
+	}
+	if s.Vars == nil {
+		s.Vars = make(map[string]string)
+	}
+	return s, nil
+}
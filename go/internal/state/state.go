@@ -3,18 +3,63 @@ package state
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"time"
 
+	"github.com/schmoli/macos-setup/internal/paths"
 	"gopkg.in/yaml.v3"
 )
 
+// State tracks installed apps by "category/name" ID
 type State struct {
-	Installed map[string]string `yaml:"installed"`
+	Installed map[string]string                `yaml:"installed"`
+	Hooks     map[string]HookRun               `yaml:"hooks,omitempty"`      // last post_install run per app
+	Dotfiles  map[string]Dotfile               `yaml:"dotfiles,omitempty"`   // deployed config file per app
+	GitConfig map[string]map[string]GitSetting `yaml:"git_config,omitempty"` // applied git settings per app
+}
+
+// GitSetting is a global git setting boots applied, with what it replaced
+type GitSetting struct {
+	Value    string `yaml:"value"`              // as applied
+	Previous string `yaml:"previous,omitempty"` // value before boots set it
+	Unset    bool   `yaml:"unset,omitempty"`    // there was no previous value
+}
+
+// Dotfile is a config file boots deployed for an app
+type Dotfile struct {
+	Source string `yaml:"source"`           // absolute path in the repo
+	Dest   string `yaml:"dest"`             // absolute target path
+	Mode   string `yaml:"mode"`             // symlink or copy
+	Hash   string `yaml:"hash,omitempty"`   // sha256 of a copy as deployed
+	Backup string `yaml:"backup,omitempty"` // where an existing file was moved
+}
+
+// HookRun is the outcome of an app's last post_install run
+type HookRun struct {
+	Ran     string       `yaml:"ran"`
+	Results []HookResult `yaml:"results"`
+}
+
+// HookResult is the outcome of one post_install command
+type HookResult struct {
+	Command string `yaml:"command"`
+	Error   string `yaml:"error,omitempty"`
+	Skipped bool   `yaml:"skipped,omitempty"` // not run after an earlier failure
+}
+
+// Failed reports whether any of commands failed in the run. Results for
+// commands no longer configured don't count.
+func (r HookRun) Failed(commands []string) bool {
+	for _, res := range r.Results {
+		if res.Error != "" && slices.Contains(commands, res.Command) {
+			return true
+		}
+	}
+	return false
 }
 
 func statePath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, ".config", "boots", "state.yaml")
+	return paths.State()
 }
 
 func Load() (*State, error) {
@@ -67,6 +112,47 @@ func (s *State) MarkInstalled(name string) {
 
 func (s *State) MarkRemoved(name string) {
 	delete(s.Installed, name)
+	delete(s.Hooks, name)
+	delete(s.Dotfiles, name)
+	delete(s.GitConfig, name)
+	s.Save() // best effort
+}
+
+// RecordGitSetting stores a git setting applied for an app
+func (s *State) RecordGitSetting(name, key string, setting GitSetting) {
+	if s.GitConfig == nil {
+		s.GitConfig = make(map[string]map[string]GitSetting)
+	}
+	if s.GitConfig[name] == nil {
+		s.GitConfig[name] = make(map[string]GitSetting)
+	}
+	s.GitConfig[name][key] = setting
+	s.Save() // best effort
+}
+
+// RecordDotfile stores a deployed config file for an app
+func (s *State) RecordDotfile(name string, d Dotfile) {
+	if s.Dotfiles == nil {
+		s.Dotfiles = make(map[string]Dotfile)
+	}
+	s.Dotfiles[name] = d
+	s.Save() // best effort
+}
+
+// ClearHooks forgets an app's post_install results
+func (s *State) ClearHooks(name string) {
+	if _, ok := s.Hooks[name]; ok {
+		delete(s.Hooks, name)
+		s.Save() // best effort
+	}
+}
+
+// RecordHooks stores the results of an app's post_install run
+func (s *State) RecordHooks(name string, results []HookResult) {
+	if s.Hooks == nil {
+		s.Hooks = make(map[string]HookRun)
+	}
+	s.Hooks[name] = HookRun{Ran: time.Now().Format("2006-01-02 15:04"), Results: results}
 	s.Save() // best effort
 }
 
@@ -74,3 +160,23 @@ func (s *State) IsTracked(name string) bool {
 	_, ok := s.Installed[name]
 	return ok
 }
+
+// Rename moves the tracking entry for old to new, keeping its date
+func (s *State) Rename(old, new string) {
+	if date, ok := s.Installed[old]; ok {
+		delete(s.Installed, old)
+		s.Installed[new] = date
+	}
+	if run, ok := s.Hooks[old]; ok {
+		delete(s.Hooks, old)
+		s.Hooks[new] = run
+	}
+	if d, ok := s.Dotfiles[old]; ok {
+		delete(s.Dotfiles, old)
+		s.Dotfiles[new] = d
+	}
+	if settings, ok := s.GitConfig[old]; ok {
+		delete(s.GitConfig, old)
+		s.GitConfig[new] = settings
+	}
+}
@@ -0,0 +1 @@
+eval "$(direnv hook bash)"
@@ -0,0 +1 @@
+direnv hook fish | source
@@ -0,0 +1 @@
+eval "$(zoxide init bash)"
@@ -0,0 +1 @@
+zoxide init fish | source